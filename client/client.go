@@ -2,6 +2,7 @@ package main
 
 import (
 	"PlanoZ/internal/models"
+	"PlanoZ/internal/utils/cardDB"
 	"bufio"
 	"context"
 	"crypto/ecdsa"
@@ -13,6 +14,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -22,6 +24,16 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// grupo do consumer no meu stream de respostas
+const GrupoCliente = "cliente"
+
+// acompanhamento das txs enviadas (GET /tx/:id/status)
+const (
+	ConfirmacoesDesejadas = 3               // para de acompanhar quando a tx chega nessa profundidade
+	IntervaloStatusTx     = 3 * time.Second // de quanto em quanto tempo pergunta
+	TempoMaximoStatusTx   = 5 * time.Minute // desiste depois disso
+)
+
 // estados possiveis do cliente
 const (
 	EstadoLivre = iota
@@ -41,6 +53,7 @@ var (
 	minhasCartas        []models.Tanque
 	indiceCartaOfertada int
 	estadoAtual         int
+	desafioPendente     *models.NotifDesafioBatalhaPayload // desafio recebido, esperando eu aceitar
 
 	// parte de infraestrutura e rede
 	serverAPI          string
@@ -88,6 +101,7 @@ func main() {
 	// 4. sobe os listeners em background
 	go listenRedis()
 	go monitorarLatencia()
+	go manterSessao()
 
 	// 5. loop principal do menu
 	reader := bufio.NewReader(os.Stdin)
@@ -113,13 +127,17 @@ func gerarChaves() {
 
 // função de criar assinatura digital pra request
 func assinarRequest(req *models.TransactionRequest) error {
-	// dados que vao ser assinados: payload + timestamp + user + tipo
+	// cada request assinado ganha um id novo, o server recusa se ver o mesmo id de novo
+	req.RequestID = uuid.New().String()
+
+	// dados que vao ser assinados: payload + timestamp + user + tipo + request id
 	// a ordem TEM que ser a mesma que o server usa pra verificar
 	dataToSign := []string{
 		req.Payload,
 		fmt.Sprintf("%d", req.Timestamp), // transformar em timestamp em string
 		req.UserID,
 		string(req.Type),
+		req.RequestID,
 	}
 
 	jsonData, _ := json.Marshal(dataToSign)
@@ -147,6 +165,9 @@ func exibirMenu() {
 		fmt.Println("4. Trocar de Servidor (Re-login)")
 		fmt.Println("5. Sair")
 		color.Blue("6. Ver Blockchain (Ledger)")
+		fmt.Println("8. Fabricar Carta (Queimar Duplicatas)")
+		fmt.Println("9. Mercado (Tokens)")
+		fmt.Println("10. Histórico de Carta")
 	case EstadoPareado:
 		fmt.Println("1. Iniciar Batalha")
 		fmt.Println("2. Iniciar Troca")
@@ -158,10 +179,18 @@ func exibirMenu() {
 	case EstadoTrocando:
 		color.Green("Negociação em andamento...")
 	}
+	if desafioPendente != nil && (estadoAtual == EstadoLivre || estadoAtual == EstadoPareado) {
+		color.Red("A. Aceitar desafio de %s", desafioPendente.Desafiante)
+	}
 	fmt.Print("Escolha: ")
 }
 
 func processarComando(input string, reader *bufio.Reader) {
+	if strings.EqualFold(input, "a") && desafioPendente != nil && (estadoAtual == EstadoLivre || estadoAtual == EstadoPareado) {
+		aceitarDesafio()
+		return
+	}
+
 	if estadoAtual == EstadoLivre {
 		switch input {
 		case "1":
@@ -175,11 +204,18 @@ func processarComando(input string, reader *bufio.Reader) {
 		case "4":
 			registrarNoServidor()
 		case "5":
+			desconectar()
 			os.Exit(0)
 		case "6":
 			verBlockchain()
 		case "7":
 			verMempool()
+		case "8":
+			fabricarCarta(reader)
+		case "9":
+			menuMercado(reader)
+		case "10":
+			verHistoricoCarta(reader)
 		default:
 			fmt.Println("Opção inválida")
 		}
@@ -225,19 +261,245 @@ func comprarBoosterSigned() {
 	body, _ := json.Marshal(req)
 	resp, err := httpClient.Post(url, "application/json", strings.NewReader(string(body)))
 	if err != nil {
-		color.Red("Erro de conexão: %v", err)
+		// api fora do ar: manda pela fila de comandos do redis, algum servidor processa
+		color.Yellow("API indisponível (%v), enviando compra pela fila de comandos...", err)
+		publicarComando("comprar_carta", models.ReqComprarCarta{
+			PlayerID:     idPessoal,
+			ReplyChannel: canalRedisResposta,
+			Request:      req,
+		})
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusAccepted {
+		var dados models.AsyncResponse
+		json.NewDecoder(resp.Body).Decode(&dados)
 		color.Green("✅ Transação enviada para a Mempool! Aguardando mineração...")
 		// nao bloqueia o usuario
+		go acompanharTx(dados.TxID, "Compra de booster")
 	} else {
 		color.Red("Erro na compra: Status %d", resp.StatusCode)
 	}
 }
 
+// função para queimar duplicatas e fabricar uma carta mais rara
+func fabricarCarta(reader *bufio.Reader) {
+	// agrupa as cartas por modelo pra achar as duplicatas
+	porModelo := make(map[string][]models.Tanque)
+	var modelos []string
+	for _, c := range minhasCartas {
+		if _, ok := porModelo[c.Modelo]; !ok {
+			modelos = append(modelos, c.Modelo)
+		}
+		porModelo[c.Modelo] = append(porModelo[c.Modelo], c)
+	}
+
+	var candidatos []string
+	for _, m := range modelos {
+		if len(porModelo[m]) >= cardDB.CRAFT_COST && porModelo[m][0].Raridade != models.RarityRare {
+			candidatos = append(candidatos, m)
+		}
+	}
+
+	if len(candidatos) == 0 {
+		color.Yellow("Você precisa de %d cartas iguais (não raras) para fabricar.", cardDB.CRAFT_COST)
+		return
+	}
+
+	color.Cyan("Modelos disponíveis para fabricação:")
+	for i, m := range candidatos {
+		fmt.Printf("%d. %s (x%d, %s)\n", i+1, m, len(porModelo[m]), porModelo[m][0].Raridade)
+	}
+	fmt.Print("Escolha o modelo: ")
+	input, _ := reader.ReadString('\n')
+
+	var escolha int
+	if _, err := fmt.Sscanf(strings.TrimSpace(input), "%d", &escolha); err != nil || escolha < 1 || escolha > len(candidatos) {
+		fmt.Println("Opção inválida")
+		return
+	}
+
+	// queima as primeiras cópias daquele modelo
+	var ids []string
+	for _, c := range porModelo[candidatos[escolha-1]][:cardDB.CRAFT_COST] {
+		ids = append(ids, c.ID)
+	}
+
+	payloadBytes, _ := json.Marshal(models.CraftPayload{CardIDs: ids})
+	req := models.TransactionRequest{
+		Type:      models.TxCraft,
+		UserID:    idPessoal,
+		Timestamp: time.Now().Unix(),
+		Payload:   string(payloadBytes),
+	}
+	if err := assinarRequest(&req); err != nil {
+		color.Red("Erro ao assinar transação: %v", err)
+		return
+	}
+
+	url := fmt.Sprintf("http://%s/cards/craft", serverAPI)
+	body, _ := json.Marshal(req)
+	resp, err := httpClient.Post(url, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		color.Red("Erro de conexão: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusAccepted {
+		color.Green("🔨 Fabricação enviada para a Mempool! Aguardando mineração...")
+	} else {
+		var erro map[string]string
+		json.NewDecoder(resp.Body).Decode(&erro)
+		color.Red("Erro na fabricação: Status %d (%s)", resp.StatusCode, erro["error"])
+	}
+}
+
+// submenu do mercado, tudo pago com os tokens ganhos em batalha
+func menuMercado(reader *bufio.Reader) {
+	color.Yellow("\n--- MERCADO ---")
+	fmt.Println("1. Ver Anúncios")
+	fmt.Println("2. Anunciar Carta")
+	fmt.Println("3. Cancelar Anúncio")
+	fmt.Println("4. Comprar Anúncio")
+	fmt.Println("5. Ver Saldo")
+	fmt.Print("Escolha: ")
+	input, _ := reader.ReadString('\n')
+
+	switch strings.TrimSpace(input) {
+	case "1":
+		verMercado()
+	case "2":
+		if len(minhasCartas) == 0 {
+			fmt.Println("Você não tem cartas para anunciar.")
+			return
+		}
+		for i, c := range minhasCartas {
+			fmt.Printf("%d. %s [%s] (%s)\n", i+1, c.Modelo, c.Raridade, c.ID)
+		}
+		fmt.Print("Carta: ")
+		cartaStr, _ := reader.ReadString('\n')
+		fmt.Print("Preço (tokens): ")
+		precoStr, _ := reader.ReadString('\n')
+
+		var indice, preco int
+		fmt.Sscanf(strings.TrimSpace(cartaStr), "%d", &indice)
+		fmt.Sscanf(strings.TrimSpace(precoStr), "%d", &preco)
+		if indice < 1 || indice > len(minhasCartas) || preco <= 0 {
+			fmt.Println("Opção inválida")
+			return
+		}
+		enviarTxAssinada("/market/list", models.TxMarketList, models.MarketListPayload{
+			CardID: minhasCartas[indice-1].ID,
+			Price:  preco,
+		})
+	case "3":
+		fmt.Print("ID do anúncio: ")
+		id, _ := reader.ReadString('\n')
+		enviarTxAssinada("/market/cancel", models.TxMarketCancel, models.MarketListingPayload{ListingID: strings.TrimSpace(id)})
+	case "4":
+		fmt.Print("ID do anúncio: ")
+		id, _ := reader.ReadString('\n')
+		enviarTxAssinada("/market/buy", models.TxMarketBuy, models.MarketListingPayload{ListingID: strings.TrimSpace(id)})
+	case "5":
+		verSaldo()
+	default:
+		fmt.Println("Opção inválida")
+	}
+}
+
+// assina e manda uma transação genérica pro servidor
+func enviarTxAssinada(endpoint string, tipo models.TransactionType, payload interface{}) {
+	payloadBytes, _ := json.Marshal(payload)
+	req := models.TransactionRequest{
+		Type:      tipo,
+		UserID:    idPessoal,
+		Timestamp: time.Now().Unix(),
+		Payload:   string(payloadBytes),
+	}
+	if err := assinarRequest(&req); err != nil {
+		color.Red("Erro ao assinar transação: %v", err)
+		return
+	}
+
+	url := fmt.Sprintf("http://%s%s", serverAPI, endpoint)
+	body, _ := json.Marshal(req)
+	resp, err := httpClient.Post(url, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		color.Red("Erro de conexão: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var data map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&data)
+	if resp.StatusCode == http.StatusAccepted {
+		color.Green("✅ %v (Tx: %v)", data["message"], data["tx_id"])
+		if txID, ok := data["tx_id"].(string); ok {
+			go acompanharTx(txID, string(tipo))
+		}
+	} else {
+		color.Red("Erro: Status %d (%v)", resp.StatusCode, data["error"])
+	}
+}
+
+// lista os anúncios ativos
+func verMercado() {
+	url := fmt.Sprintf("http://%s/market", serverAPI)
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		color.Red("Erro: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Count    int                    `json:"count"`
+		Listings []models.MarketListing `json:"listings"`
+	}
+	json.NewDecoder(resp.Body).Decode(&data)
+
+	color.Cyan("Anúncios ativos: %d", data.Count)
+	for _, l := range data.Listings {
+		fmt.Printf("- [%s] %s (%s, %s) por %d tokens | vendedor: %s\n", l.ID, l.Card.Modelo, l.Card.Raridade, l.Card.Categoria, l.Price, l.SellerID)
+	}
+}
+
+// mostra o saldo de tokens confirmado e pendente
+func verSaldo() {
+	url := fmt.Sprintf("http://%s/market/balance/%s", serverAPI, idPessoal)
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		color.Red("Erro: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Balance int `json:"balance"`
+		Pending int `json:"pending"`
+	}
+	json.NewDecoder(resp.Body).Decode(&data)
+	color.Cyan("💰 Saldo: %d tokens (com pendentes: %d)", data.Balance, data.Pending)
+}
+
+// publica um comando na fila do redis (stream "cmd:<topico>")
+// exatamente um servidor do cluster vai pegar, processar e responder no meu canal
+func publicarComando(topico string, payload interface{}) bool {
+	data, _ := json.Marshal(payload)
+	err := redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: "cmd:" + topico,
+		Values: map[string]interface{}{"payload": string(data)},
+	}).Err()
+	if err != nil {
+		color.Red("Erro ao publicar comando %s: %v", topico, err)
+		return false
+	}
+	color.Cyan("📨 Comando %s enviado pela fila, aguardando resposta...", topico)
+	return true
+}
+
 // função para ber o ledger
 func verBlockchain() {
 	url := fmt.Sprintf("http://%s/blockchain/", serverAPI)
@@ -257,6 +519,119 @@ func verBlockchain() {
 	bufio.NewReader(os.Stdin).ReadString('\n')
 }
 
+// mostra a proveniência de uma carta (mint, trocas, batalhas, craft, mercado)
+func verHistoricoCarta(reader *bufio.Reader) {
+	if len(minhasCartas) > 0 {
+		for i, c := range minhasCartas {
+			fmt.Printf("%d. %s [%s] (%s)\n", i+1, c.Modelo, c.Raridade, c.ID)
+		}
+	}
+	fmt.Print("Número da carta ou ID: ")
+	entrada, _ := reader.ReadString('\n')
+	entrada = strings.TrimSpace(entrada)
+
+	cardID := entrada
+	if indice, err := strconv.Atoi(entrada); err == nil && indice >= 1 && indice <= len(minhasCartas) {
+		cardID = minhasCartas[indice-1].ID
+	}
+	if cardID == "" {
+		fmt.Println("Opção inválida")
+		return
+	}
+
+	url := fmt.Sprintf("http://%s/cards/%s/history", serverAPI, cardID)
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		color.Red("Erro: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		color.Yellow("Carta sem histórico no ledger (ainda não minerada?)")
+		return
+	}
+
+	var historico models.CardHistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&historico); err != nil {
+		color.Red("Resposta inválida: %v", err)
+		return
+	}
+
+	color.Cyan("Histórico da carta %s (dono atual: %s)", historico.CardID, historico.Owner)
+	for _, ev := range historico.Events {
+		data := time.Unix(ev.Timestamp, 0).Format("02/01 15:04")
+		switch ev.Event {
+		case models.CardEventMint:
+			fmt.Printf("#%d %s 📦 saiu num booster de %s\n", ev.Height, data, ev.To)
+		case models.CardEventTrade:
+			fmt.Printf("#%d %s 🤝 trocada: %s -> %s\n", ev.Height, data, ev.From, ev.To)
+		case models.CardEventBattle:
+			fmt.Printf("#%d %s ⚔️  batalha %s com %s (vencedor: %s)\n", ev.Height, data, ev.BattleID, ev.From, ev.Winner)
+		case models.CardEventCraft:
+			fmt.Printf("#%d %s 🔨 fabricada por %s\n", ev.Height, data, ev.To)
+		case models.CardEventBurn:
+			fmt.Printf("#%d %s 🔥 queimada por %s\n", ev.Height, data, ev.From)
+		case models.CardEventMarketList:
+			fmt.Printf("#%d %s 🏷️  anunciada por %s por %s tokens\n", ev.Height, data, ev.From, ev.Price)
+		case models.CardEventMarketCancel:
+			fmt.Printf("#%d %s ❌ anúncio cancelado por %s\n", ev.Height, data, ev.From)
+		case models.CardEventMarketSale:
+			fmt.Printf("#%d %s 🛒 vendida: %s -> %s por %s tokens\n", ev.Height, data, ev.From, ev.To, ev.Price)
+		default:
+			fmt.Printf("#%d %s %s\n", ev.Height, data, ev.Event)
+		}
+	}
+	fmt.Println("Pressione Enter para voltar.")
+	reader.ReadString('\n')
+}
+
+// pergunta o status da tx até ela ficar funda o bastante na cadeia (ou falhar)
+// só imprime quando muda alguma coisa, pra não poluir o menu
+func acompanharTx(txID, descricao string) {
+	if txID == "" {
+		return
+	}
+
+	url := fmt.Sprintf("http://%s/tx/%s/status", serverAPI, txID)
+	limite := time.Now().Add(TempoMaximoStatusTx)
+	var ultimo models.TxStatusResponse
+
+	for time.Now().Before(limite) {
+		time.Sleep(IntervaloStatusTx)
+
+		resp, err := httpClient.Get(url)
+		if err != nil {
+			continue
+		}
+		var st models.TxStatusResponse
+		json.NewDecoder(resp.Body).Decode(&st)
+		resp.Body.Close()
+
+		if st.Status == ultimo.Status && st.Confirmations == ultimo.Confirmations {
+			continue
+		}
+		ultimo = st
+
+		switch st.Status {
+		case models.TxStatusPending:
+			color.Cyan("\n⏳ %s: na mempool, esperando mineração (Tx: %s)", descricao, txID)
+		case models.TxStatusMined:
+			color.Green("\n⛏️  %s: bloco #%d, %d/%d confirmações", descricao, st.Height, st.Confirmations, ConfirmacoesDesejadas)
+			if st.Confirmations >= ConfirmacoesDesejadas {
+				return
+			}
+		case models.TxStatusRejected:
+			color.Red("\n❌ %s: recusada (%s)", descricao, st.Reason)
+			return
+		case models.TxStatusDropped:
+			color.Yellow("\n🗑️  %s: descartada (%s)", descricao, st.Reason)
+			return
+		}
+	}
+	color.Yellow("\n⌛ %s: parei de acompanhar a Tx %s (status: %s)", descricao, txID, ultimo.Status)
+}
+
 // função auxiliar para ver a mempool
 func verMempool() {
 	url := fmt.Sprintf("http://%s/blockchain/mempool", serverAPI)
@@ -276,106 +651,268 @@ func verMempool() {
 }
 
 // função para começar a ouvir respostas do server
+// o canal de resposta é um stream do redis com um consumer group só meu:
+// toda mensagem fica guardada até eu dar XACK, então se a conexão cair no meio,
+// ao voltar eu releio as pendentes (id "0") antes de pegar as novas (">")
 func listenRedis() {
-	pubsub := redisClient.Subscribe(ctx, canalRedisResposta)
-	defer pubsub.Close()
+	err := redisClient.XGroupCreateMkStream(ctx, canalRedisResposta, GrupoCliente, "0").Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		color.Red("Erro ao criar grupo no canal de resposta: %v", err)
+	}
+
+	ultimoID := "" // ultima mensagem tratada, pra não repetir em replay
+	pendentes := true
 
 	for {
-		msg, err := pubsub.ReceiveMessage(ctx)
+		inicio := ">"
+		if pendentes {
+			inicio = "0" // replay do que foi entregue e não confirmado
+		}
+
+		streams, err := redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    GrupoCliente,
+			Consumer: idPessoal,
+			Streams:  []string{canalRedisResposta, inicio},
+			Count:    10,
+			Block:    5 * time.Second,
+		}).Result()
 		if err != nil {
-			time.Sleep(1 * time.Second)
+			if err != redis.Nil {
+				// caiu a conexão: na volta começa pelas pendentes
+				pendentes = true
+				time.Sleep(1 * time.Second)
+			}
 			continue
 		}
 
-		var envelope map[string]interface{} // envelope generico pra receber os dados
-		json.Unmarshal([]byte(msg.Payload), &envelope)
+		lidas := 0
+		for _, st := range streams {
+			for _, msg := range st.Messages {
+				lidas++
+				if msg.ID != ultimoID {
+					data, _ := msg.Values["data"].(string)
+					tratarMensagem([]byte(data))
+					ultimoID = msg.ID
+				}
+				redisClient.XAck(ctx, canalRedisResposta, GrupoCliente, msg.ID)
+			}
+		}
 
-		tipo := envelope["tipo"].(string)
+		// terminou o replay, passa a ler só as novas
+		if pendentes && lidas == 0 {
+			pendentes = false
+		}
+	}
+}
 
-		// converte payload para json e depois para struct
-		payloadBytes, _ := json.Marshal(envelope["payload"])
+// trata uma notificação do servidor
+// mensagem malformada ou de tipo desconhecido só gera aviso, nunca derruba o cliente
+func tratarMensagem(data []byte) {
+	msg, err := models.DecodeNotification(data)
+	if err != nil {
+		color.Yellow("\n⚠️  Notificação ignorada: %v", err)
+		return
+	}
 
-		switch tipo {
+	switch msg.Tipo {
 
-		// respostas vindas da blockchain
-		case "Compra_Sucesso":
-			var dados struct {
-				Mensagem string         `json:"mensagem"`
-				Booster  models.Booster `json:"booster"`
-				TxID     string         `json:"tx_id"`
-			}
-			json.Unmarshal(payloadBytes, &dados)
-			color.Green("\n💰 [BLOCKCHAIN] %s", dados.Mensagem)
-			color.White("Bloco Minerado! TxID: %s", dados.TxID)
-			color.Yellow("Você recebeu %d cartas:", len(dados.Booster.Cards))
-			for _, c := range dados.Booster.Cards {
-				fmt.Printf("- %s (%s)\n", c.Modelo, c.Raridade)
-				minhasCartas = append(minhasCartas, c)
-			}
-			exibirMenu() // atualiza o menu
+	// respostas vindas da blockchain
+	case models.NotifCompraSucesso:
+		var dados models.NotifCompraSucessoPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Green("\n💰 [BLOCKCHAIN] %s", dados.Mensagem)
+		color.White("Bloco Minerado! TxID: %s", dados.TxID)
+		color.Yellow("Você recebeu %d cartas:", len(dados.Booster.Cards))
+		for _, c := range dados.Booster.Cards {
+			fmt.Printf("- %s (%s, %s)\n", c.Modelo, c.Raridade, c.Categoria)
+			minhasCartas = append(minhasCartas, c)
+		}
+		exibirMenu() // atualiza o menu
+
+	case models.NotifTrocaConfirmada:
+		var dados models.NotifTrocaConfirmadaPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Green("\n🤝 [BLOCKCHAIN] %s (Tx: %s)", dados.Mensagem, dados.TxID)
+		estadoAtual = EstadoLivre
+		exibirMenu()
+
+	case models.NotifCraftSucesso:
+		var dados models.NotifCraftSucessoPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Magenta("\n🔨 [BLOCKCHAIN] %s (Tx: %s)", dados.Mensagem, dados.TxID)
 
-		case "Troca_Confirmada":
-			var dados struct {
-				Msg  string `json:"mensagem"`
-				TxID string `json:"tx_id"`
+		// tira as queimadas do inventário e adiciona a nova
+		queimadas := make(map[string]bool)
+		for _, id := range dados.Queimadas {
+			queimadas[id] = true
+		}
+		restantes := []models.Tanque{}
+		for _, c := range minhasCartas {
+			if !queimadas[c.ID] {
+				restantes = append(restantes, c)
 			}
-			json.Unmarshal(payloadBytes, &dados)
-			color.Green("\n🤝 [BLOCKCHAIN] %s (Tx: %s)", dados.Msg, dados.TxID)
-			estadoAtual = EstadoLivre
-			exibirMenu()
+		}
+		minhasCartas = append(restantes, dados.Carta)
+		color.Yellow("Nova carta: %s (%s, %s)", dados.Carta.Modelo, dados.Carta.Raridade, dados.Carta.Categoria)
+		exibirMenu()
 
-		case "Rank_Update":
-			color.Yellow("\n🏆 [BLOCKCHAIN] Vitória registrada no ledger!")
+	// respostas da fila de comandos
+	case models.NotifConectado:
+		var dados models.NotifConectadoPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Green("\n📨 %s (servidor %s)", dados.Mensagem, dados.ServerID)
 
-		case "Inicio_Batalha":
-			var p models.RespostaInicioBatalha
-			json.Unmarshal(payloadBytes, &p)
-			color.Red("\n⚔️ Batalha iniciada contra %s!", p.Mensagem)
-			idBatalha = p.IdBatalha
-			estadoAtual = EstadoBatalhando
-			go loopBatalha()
+	case models.NotifCompraAceita:
+		var dados models.AsyncResponse
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Green("\n📨 %s (Tx: %s)", dados.Message, dados.TxID)
+		go acompanharTx(dados.TxID, "Compra de booster")
 
-		case "Inicio_Troca":
-			var p models.RespostaInicioTroca
-			json.Unmarshal(payloadBytes, &p)
-			color.Green("\n🤝 Troca iniciada com %s!", p.Mensagem)
-			idTroca = p.IdTroca
-			estadoAtual = EstadoTrocando
-			go loopTroca()
+	case models.NotifTxProvisoria:
+		var dados models.NotifTxProvisoriaPayload
+		if !decodificar(msg, &dados) {
+			return
 		}
-	}
-}
+		color.Cyan("\n⏳ %s (bloco #%d, %d/%d confirmações)", dados.Mensagem, dados.Altura, dados.Confirmacoes, dados.Necessarias)
 
+	case models.NotifTxRevertida:
+		var dados models.NotifTxRevertidaPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Red("\n↩️  %s (Tx: %s)", dados.Mensagem, dados.TxID)
 
-func loopBatalha() {
-	
-	fmt.Println("Iniciando batalha")
-	time.Sleep(2 * time.Second)
-	registrarResultadoBatalha(idBatalha, idPessoal)
-	estadoAtual = EstadoPareado
-}
+	case models.NotifCompraErro:
+		var dados models.NotifCompraErroPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Red("\n📨 Compra recusada: %s", dados.Mensagem)
 
-func registrarResultadoBatalha(battleID, winnerID string) {
-	payloadObj := models.BattleResultPayload{
-		BattleID: battleID,
-		Winner:   winnerID,
-	}
-	payloadBytes, _ := json.Marshal(payloadObj)
+	case models.NotifRankUpdate:
+		var dados models.NotifRankUpdatePayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Yellow("\n🏆 [BLOCKCHAIN] Vitória registrada no ledger! +%d tokens", dados.Tokens)
 
-	req := models.TransactionRequest{
-		Type:      models.TxBattleResult,
-		UserID:    idPessoal,
-		Timestamp: time.Now().Unix(),
-		Payload:   string(payloadBytes),
-	}
-	assinarRequest(&req)
+	case models.NotifMercadoAnunciado, models.NotifMercadoCancelado:
+		var dados models.NotifMercadoAnuncioPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Cyan("\n🏷️  [MERCADO] %s (Anúncio: %s)", dados.Mensagem, dados.ListingID)
 
-	url := fmt.Sprintf("http://%s/battle/register", serverAPI)
-	body, _ := json.Marshal(req)
-	httpClient.Post(url, "application/json", strings.NewReader(string(body)))
-	fmt.Println("Resultado enviado para Blockchain.")
+	case models.NotifMercadoComprado:
+		var dados models.NotifMercadoCompradoPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Green("\n🛒 [MERCADO] %s %s por %s tokens", dados.Mensagem, dados.Carta.Modelo, dados.Preco)
+		minhasCartas = append(minhasCartas, dados.Carta)
+
+	case models.NotifMercadoVendido:
+		var dados models.NotifMercadoVendidoPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Green("\n💸 [MERCADO] %s (+%s tokens)", dados.Mensagem, dados.Preco)
+		for i, c := range minhasCartas {
+			if c.ID == dados.CardID {
+				minhasCartas = append(minhasCartas[:i], minhasCartas[i+1:]...)
+				break
+			}
+		}
+
+	// batalha
+	case models.NotifDesafioBatalha:
+		var dados models.NotifDesafioBatalhaPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		// o desafio assinado só vale pela janela do request (2 min)
+		color.Red("\n⚔️ %s (digite A para aceitar)", dados.Mensagem)
+		desafioPendente = &dados
+
+	case models.NotifInicioBatalha:
+		var p models.RespostaInicioBatalha
+		if !decodificar(msg, &p) {
+			return
+		}
+		// quem resolve os turnos é o servidor host, aqui só acompanha
+		color.Red("\n⚔️ Batalha iniciada: %s!", p.Mensagem)
+		idBatalha = p.IdBatalha
+		estadoAtual = EstadoBatalhando
+		desafioPendente = nil
+
+	case models.NotifSuaVezTroca:
+		var dados models.NotifSuaVezPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Cyan("\n🎯 %s", dados.Mensagem)
+
+	case models.NotifResultadoTurno:
+		var dados models.NotifBatalhaPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.White("\n💥 %s", dados.Resultado)
+
+	case models.NotifFimBatalha:
+		var dados models.NotifBatalhaPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Red("\n🏁 Fim da batalha: %s", dados.Resultado)
+		// o host já registrou o resultado na chain; o token chega quando o bloco ficar final
+		if dados.Vencedor == idPessoal {
+			color.Yellow("🏆 Vitória! +%d tokens quando o resultado for confirmado", models.BattleReward)
+		}
+		estadoAtual = EstadoPareado
+
+	// troca
+	case models.NotifInicioTroca:
+		var p models.RespostaInicioTroca
+		if !decodificar(msg, &p) {
+			return
+		}
+		color.Green("\n🤝 Troca iniciada com %s!", p.Mensagem)
+		idTroca = p.IdTroca
+		estadoAtual = EstadoTrocando
+		go loopTroca()
+
+	case models.NotifResultadoTroca:
+		var dados models.NotifResultadoTrocaPayload
+		if !decodificar(msg, &dados) {
+			return
+		}
+		color.Green("\n🤝 Você recebeu %s (%s)", dados.CartaRecebida.Modelo, dados.CartaRecebida.Raridade)
+
+	default:
+		// servidor mais novo pode mandar tipos que esse cliente ainda não conhece
+		color.Yellow("\n⚠️  Notificação de tipo desconhecido ignorada: %s", msg.Tipo)
+	}
 }
 
+// decodifica o payload e avisa se veio quebrado
+func decodificar(msg models.Notification, v interface{}) bool {
+	if err := msg.Decode(v); err != nil {
+		color.Yellow("\n⚠️  Notificação ignorada: %v", err)
+		return false
+	}
+	return true
+}
 
 // função auxiliar para ver inventário
 func verCartas() {
@@ -384,7 +921,11 @@ func verCartas() {
 		fmt.Println("(Vazio)")
 	}
 	for i, c := range minhasCartas {
-		fmt.Printf("%d. %s [%s] (Atk: %d | HP: %d)\n", i+1, c.Modelo, c.Raridade, c.Ataque, c.Vida)
+		fmt.Printf("%d. %s [%s | %s | %s] (Atk: %d | HP: %d | Blind: %d | Vel: %d)", i+1, c.Modelo, c.Raridade, c.Categoria, c.Edicao, c.Ataque, c.Vida, c.Blindagem, c.Velocidade)
+		if c.Habilidade != models.AbilityNone {
+			fmt.Printf(" * %s", c.Habilidade)
+		}
+		fmt.Println()
 	}
 	fmt.Println("Pressione Enter.")
 	bufio.NewReader(os.Stdin).ReadString('\n')
@@ -403,19 +944,40 @@ func registrarNoServidor() bool {
 	}
 	body, _ := json.Marshal(req)
 
-	// manda pro server atual (assumindo que ele é lider ou repassa)
-	resp, err := httpClient.Post(url, "application/json", strings.NewReader(string(body)))
-	if err != nil {
-		color.Red("Erro ao conectar no servidor %s: %v", serverAPI, err)
-		return false
+	// manda pro server atual; se for seguidor ele repassa pro líder ou responde
+	// 307 com o endereço do líder, que o http client segue sozinho
+	var resp *http.Response
+	var err error
+	for tentativa := 1; tentativa <= 5; tentativa++ {
+		resp, err = httpClient.Post(url, "application/json", strings.NewReader(string(body)))
+		if err != nil {
+			// api fora do ar: tenta registrar pela fila de comandos do redis
+			color.Red("Erro ao conectar no servidor %s: %v", serverAPI, err)
+			return publicarComando("conectar", models.ReqConectar{
+				PlayerID:     idPessoal,
+				ReplyChannel: canalRedisResposta,
+			})
+		}
+		// 503 = cluster ainda sem líder (eleição em andamento), espera e tenta de novo
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			break
+		}
+		resp.Body.Close()
+		color.Yellow("Cluster sem líder no momento, tentando de novo (%d/5)...", tentativa)
+		time.Sleep(2 * time.Second)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		color.Red("Servidor rejeitou conexão")
+		color.Red("Servidor rejeitou conexão (status %d)", resp.StatusCode)
 		return false
 	}
 
+	// se foi redirecionado, mostra quem atendeu de fato
+	if lider := resp.Request.URL.Host; lider != serverAPI {
+		color.Cyan("Registro atendido pelo líder %s", lider)
+	}
+
 	color.Green("Conectado ao servidor %s com sucesso!", serverAPI)
 	serverUDP = strings.Split(serverAPI, ":")[0] + ":8083"
 	return true
@@ -452,6 +1014,47 @@ func conectarRedis() {
 	}
 }
 
+// manda heartbeat pra sessão no registro do servidor não expirar
+// se a sessão já expirou (ex, ficou muito tempo sem rede), registra de novo
+func manterSessao() {
+	for {
+		time.Sleep(20 * time.Second)
+
+		resp, err := enviarSessaoAssinada("/players/heartbeat", models.ReqHeartbeat)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			color.Yellow("\nSessão expirada, registrando de novo...")
+			registrarNoServidor()
+		}
+	}
+}
+
+// avisa o servidor que o jogador saiu (remove a sessão na hora)
+func desconectar() {
+	resp, err := enviarSessaoAssinada("/players/disconnect", models.ReqDisconnect)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// heartbeat e disconnect vão assinados (cada um com request_id novo), senão o servidor recusa
+func enviarSessaoAssinada(endpoint string, tipo models.TransactionType) (*http.Response, error) {
+	req := models.TransactionRequest{
+		Type:      tipo,
+		UserID:    idPessoal,
+		Timestamp: time.Now().Unix(),
+	}
+	if err := assinarRequest(&req); err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(req)
+	return httpClient.Post(fmt.Sprintf("http://%s%s", serverAPI, endpoint), "application/json", strings.NewReader(string(body)))
+}
+
 func monitorarLatencia() {
 
 	for {
@@ -484,12 +1087,46 @@ func monitorarLatencia() {
 func parear(id string) { idParceiro = id; estadoAtual = EstadoPareado; fmt.Println("Pareado com", id) }
 func desparear()       { idParceiro = ""; estadoAtual = EstadoLivre; fmt.Println("Despareado") }
 
+// desafia o parceiro: o servidor repassa o pedido assinado e a batalha só começa quando ele aceitar
 func solicitarBatalha() {
-	url := fmt.Sprintf("http://%s/battle/initiate", serverAPI)
-	req := models.BattleInitiateRequest{IdBatalha: uuid.New().String(), IdJogadorLocal: idPessoal, IdOponente: idParceiro, HostServidor: serverAPI}
+	enviarPedidoBatalha(models.BattleStartPayload{BattleID: uuid.New().String(), Opponent: idParceiro})
+	fmt.Println("Desafio de batalha enviado, aguardando o oponente aceitar...")
+}
+
+// aceita o desafio recebido mandando ele de volta dentro do meu pedido assinado
+func aceitarDesafio() {
+	d := desafioPendente
+	desafioPendente = nil
+	enviarPedidoBatalha(models.BattleStartPayload{BattleID: d.IdBatalha, Opponent: d.Desafiante, Challenge: &d.Desafio})
+}
+
+func enviarPedidoBatalha(payload models.BattleStartPayload) {
+	payloadBytes, _ := json.Marshal(payload)
+	req := models.TransactionRequest{
+		Type:      models.ReqBattleStart,
+		UserID:    idPessoal,
+		Timestamp: time.Now().Unix(),
+		Payload:   string(payloadBytes),
+	}
+	if err := assinarRequest(&req); err != nil {
+		color.Red("Erro ao assinar pedido de batalha: %v", err)
+		return
+	}
+
+	url := fmt.Sprintf("http://%s/battle/start", serverAPI)
 	body, _ := json.Marshal(req)
-	httpClient.Post(url, "application/json", strings.NewReader(string(body)))
-	fmt.Println("Solicitação de batalha enviada...")
+	resp, err := httpClient.Post(url, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		color.Red("Erro de conexão: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		var data map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&data)
+		color.Red("Batalha recusada: Status %d (%v)", resp.StatusCode, data["error"])
+	}
 }
 func solicitarTroca() {
 	url := fmt.Sprintf("http://%s/trade/initiate", serverAPI)
@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// resultado de batalha (BR): quem registra é o servidor host que resolveu a batalha,
+// assinado com a chave do nó dele. jogador não consegue montar um BR, então a recompensa
+// só sai de batalha que um servidor do cluster iniciou e terminou
+// [0]BattleID, [1]HostID, [2]Winner, [3]Meta, [4]CardsJSON, [5]Jogador1, [6]Jogador2
+// o UserData segue o formato dos requests ([payload, timestamp, hostID, "BR", battleID]),
+// então janela de validade e anti-replay valem igual: um BR por batalha
+
+// monta e assina o BR de uma batalha encerrada no host
+func NewBattleResult(hostID string, key *ecdsa.PrivateKey, result models.BattleResultPayload) (models.Transaction, error) {
+	if len(result.Players) != 2 {
+		return models.Transaction{}, fmt.Errorf("battle %s needs two players", result.BattleID)
+	}
+	if result.Cards == nil {
+		result.Cards = []string{}
+	}
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	cards, _ := json.Marshal(result.Cards)
+
+	now := time.Now().Unix()
+	userData := []string{string(payload), fmt.Sprintf("%d", now), hostID, string(models.TxBattleResult), result.BattleID}
+	sig, err := signData(key, userData)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	return models.Transaction{
+		ID:        "battle-" + result.BattleID,
+		Type:      models.TxBattleResult,
+		Timestamp: now,
+		Data: []string{
+			result.BattleID,
+			hostID,
+			result.Winner,
+			"BATTLE_END",
+			string(cards),
+			result.Players[0],
+			result.Players[1],
+		},
+		UserData:  userData,
+		PublicKey: elliptic.Marshal(elliptic.P256(), key.PublicKey.X, key.PublicKey.Y),
+		Signature: sig,
+	}, nil
+}
+
+// o BR bate com o que o host assinou e foi assinado pela chave registrada do host
+func validateBattleResult(tx *models.Transaction, nodeKeys NodeKeyLookup) error {
+	d, u := tx.Data, tx.UserData
+	if len(d) < 7 || len(u) < 5 {
+		return ruleError(ErrTxFormat, tx.ID, "malformed battle result")
+	}
+	if u[2] != d[1] || u[3] != string(models.TxBattleResult) || u[4] != d[0] {
+		return ruleError(ErrTxFormat, tx.ID, "battle result not signed by its host")
+	}
+
+	var signed models.BattleResultPayload
+	if err := json.Unmarshal([]byte(u[0]), &signed); err != nil {
+		return ruleError(ErrTxFormat, tx.ID, "invalid battle payload: %v", err)
+	}
+	cards, _ := json.Marshal(signed.Cards)
+	if signed.BattleID != d[0] || signed.Winner != d[2] || string(cards) != d[4] ||
+		len(signed.Players) != 2 || signed.Players[0] != d[5] || signed.Players[1] != d[6] {
+		return ruleError(ErrTxFormat, tx.ID, "battle data does not match signed result")
+	}
+	if d[2] != "" && d[2] != d[5] && d[2] != d[6] {
+		return ruleError(ErrTxFormat, tx.ID, "winner %s did not play", d[2])
+	}
+
+	if err := signedByNode(nodeKeys, d[1], tx.PublicKey); err != nil {
+		return ruleError(ErrUnknownSigner, tx.ID, "%v", err)
+	}
+	return nil
+}
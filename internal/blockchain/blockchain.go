@@ -3,10 +3,12 @@ package blockchain
 import (
 	"PlanoZ/internal/models"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 )
 
 type Blockchain struct {
@@ -16,27 +18,51 @@ type Blockchain struct {
 	IncomingBlocks chan BlockTask // canal pra receber blocos da rede
 	StateChan      *chan int      // controle da mineracao
 	MX             sync.Mutex     // mutex pra proteger a mempool
+	miner          *minerIdentity // quem assina a coinbase dos blocos minerados aqui
+	nodeKeys       NodeKeyLookup  // chave registrada de cada servidor (confere coinbase e BR)
+	catalog        CardCatalog    // definições das cartas (confere a carta fabricada no CF)
+	explorer       *explorerIndex // índices de leitura (explorer.go), lock próprio
+	subscribers    chainSubscribers
+	tracked        map[string]*txRecord // ciclo de vida das txs fora da cadeia (tracker.go)
 }
 
 // inicializa a blockchain
 func New() *Blockchain {
 	channel := make(chan int)
 	// comeca com o genesis
-	return &Blockchain{
+	b := &Blockchain{
 		Height:         1,
 		Ledger:         []*Block{Genesis()},
 		MPool:          []models.Transaction{},
 		IncomingBlocks: make(chan BlockTask, 10),
 		StateChan:      &channel,
 		MX:             sync.Mutex{},
+		explorer:       newExplorerIndex(),
 	}
+	b.explorer.rebuild(b.Ledger)
+	return b
 }
 
 // valida a tx e joga na mempool se tiver tudo ok
+// o resultado fica no tracker (pendente ou recusada com o motivo) pro GET /tx/:id/status
 func (b *Blockchain) AddTransaction(tx models.Transaction) error {
 	b.MX.Lock()
 	defer b.MX.Unlock()
 
+	err := b.addTransaction(tx)
+	switch {
+	case err == nil:
+		b.track(tx.ID, models.TxStatusPending, "")
+	case errors.Is(err, errDuplicateTx) || tx.ID == "":
+		// já conhecida (mantém o status que tinha) ou sem id pra acompanhar
+	default:
+		b.track(tx.ID, models.TxStatusRejected, err.Error())
+	}
+	return err
+}
+
+// chamar com o MX travado
+func (b *Blockchain) addTransaction(tx models.Transaction) error {
 	// 1. verifica se a assinatura eh valida
 	if !VerifySignature(tx.PublicKey, tx.UserData, tx.Signature) {
 		slog.Error("Blockchain: Assinatura inválida", "txID", tx.ID)
@@ -46,41 +72,105 @@ func (b *Blockchain) AddTransaction(tx models.Transaction) error {
 	// 2. anti-replay (ver se está sendo mandado a mesma coisa)
 	if !b.AntiReplay(tx.ID) {
 		slog.Error("Blockchain: Transação duplicada (Replay Attack)", "txID", tx.ID)
-		return errors.New("duplicated transaction")
+		return errDuplicateTx
+	}
+
+	// 3. coinbase só quem cria é o minerador, dentro do bloco
+	if tx.Type == models.TxCoinbase {
+		return errors.New("coinbase transactions cannot be submitted")
 	}
 
-	// 3. validacao de campos obrigatorios
+	// 4. validacao de campos obrigatorios
 	if err := b.ValidateFormat(tx); err != nil {
 		slog.Error("Blockchain: Formato inválido", "error", err)
 		return err
 	}
 
+	// resultado de batalha só vale assinado pelo servidor que hospedou
+	if tx.Type == models.TxBattleResult {
+		if err := validateBattleResult(&tx, b.nodeKeys); err != nil {
+			slog.Error("Blockchain: Resultado de batalha recusado", "txID", tx.ID, "error", err)
+			return err
+		}
+	}
+
+	// 5. request assinado dentro da janela e ainda não usado por essa chave
+	now := time.Now()
+	if err := checkTxWindow(&tx, now.Unix()); err != nil {
+		slog.Error("Blockchain: Tx fora da janela de validade", "txID", tx.ID, "error", err)
+		return err
+	}
+	if b.requestUsed(requestKey(&tx), now) {
+		slog.Error("Blockchain: Request reutilizado (Replay Attack)", "txID", tx.ID)
+		return ruleError(ErrRequestReplay, tx.ID, "")
+	}
+
 	// adiciona na fila
 	b.MPool = append(b.MPool, tx)
+	b.publish(ChainEvent{Kind: ChainTxAccepted, Tx: &tx})
 	// fmt.Printf("Transação adicionada à Mempool: %s (%s)\n", tx.ID, tx.Type)
 	return nil
 }
 
 // pega txs da mempool e tenta fechar um bloco
 func (b *Blockchain) MineBlock() (*Block, error) {
+	return b.mineBlock(false)
+}
+
+// bloco mesmo com a mempool vazia (só coinbase + o que tiver pendente)
+// serve pra dar confirmações aos blocos anteriores quando a rede está parada
+func (b *Blockchain) MineConfirmationBlock() (*Block, error) {
+	return b.mineBlock(true)
+}
+
+func (b *Blockchain) mineBlock(allowEmpty bool) (*Block, error) {
 	b.MX.Lock()
-	// pega o que tem pendente
+	// pega o que tem pendente (o que expirou não pode mais entrar em bloco)
+	b.expireMempool(time.Now())
 	count := len(b.MPool)
-	if count == 0 {
+	if count == 0 && !allowEmpty {
 		b.MX.Unlock()
 		return nil, errors.New("no transactions to mine")
 	}
-	if count > 50 {
-		count = 50
+	if b.miner == nil {
+		b.MX.Unlock()
+		return nil, errNoMiner
+	}
+	// um lugar fica pra coinbase
+	if count > MaxBlockTxs-1 {
+		count = MaxBlockTxs - 1
 	}
 
-	txsToMine := make([]*models.Transaction, count)
-	for i := 0; i < count; i++ {
-		tx := b.MPool[i]
-		txsToMine[i] = &tx
+	prevHash := b.Ledger[len(b.Ledger)-1].Hash
+	coinbase, err := newCoinbase(b.miner, len(b.Ledger), prevHash)
+	if err != nil {
+		b.MX.Unlock()
+		return nil, err
 	}
 
-	prevHash := b.Ledger[len(b.Ledger)-1].Hash
+	// só entra o que se aplica no estado, em ordem (os outros nós recusariam o bloco)
+	// o que não se aplica mais (ex, carta já gasta por outra tx) sai da mempool
+	state := b.buildState(false)
+	state.Apply(coinbase)
+	txsToMine := make([]*models.Transaction, 0, count+1)
+	txsToMine = append(txsToMine, coinbase)
+	pool := b.MPool[:0]
+	for _, tx := range b.MPool {
+		tx := tx
+		if err := state.Apply(&tx); err != nil {
+			b.track(tx.ID, models.TxStatusDropped, err.Error())
+			continue
+		}
+		pool = append(pool, tx)
+		if len(txsToMine) <= count {
+			txsToMine = append(txsToMine, &tx)
+		}
+	}
+	b.MPool = pool
+	if len(txsToMine) == 1 && !allowEmpty {
+		b.MX.Unlock()
+		return nil, errors.New("no transactions to mine")
+	}
 	b.MX.Unlock() // libera o lock devido demora do pow
 
 	// comeca a mineracao
@@ -103,56 +193,120 @@ func (b *Blockchain) AddBlock(block *Block) {
 	// adiciona no final da cadeia
 	b.Ledger = append(b.Ledger, block)
 	b.Height++
+	b.explorer.add(block)
+	b.publish(ChainEvent{Kind: ChainBlockAdded, Height: len(b.Ledger) - 1, Block: block})
 
 	// remove da mempool as txs que entraram nesse bloco
 	// cria mapa para busca rapida
 	minedIDs := make(map[string]bool)
 	for _, tx := range block.Transactions {
-		minedIDs[tx.ID] = true
+		markMined(minedIDs, tx)
 	}
 
 	// recria a mpool só com o que sobrou
-	newPool := []models.Transaction{}
-	for _, tx := range b.MPool {
-		if !minedIDs[tx.ID] {
-			newPool = append(newPool, tx)
-		}
-	}
-	b.MPool = newPool
+	b.MPool = b.pendingAfter(minedIDs)
+	b.pruneTracked(minedIDs)
 
 	fmt.Printf("⛓️  Bloco #%d adicionado! Hash: %x | Txs: %d\n", b.Height, block.Hash[:4], len(block.Transactions))
 }
 
-// verifica se o bloco que chegou de outro nó é válido
+// verifica se o bloco que chegou de outro nó é válido (regras em validation.go)
+// aqui que entra o consenso de nakamoto sobre a cadeia mais longa (height)
 func (b *Blockchain) CheckNewBlock(block *Block) error {
-	// 1. verifica se encaixa no hash anterior
-	// aqui que entra o consenso de nakamoto sobre a cadeia mais longa (height)
+	b.MX.Lock()
+	defer b.MX.Unlock()
+
 	lastBlock := b.Ledger[len(b.Ledger)-1]
-	if !bytes.Equal(block.PreviousHash, lastBlock.Hash) {
-		return fmt.Errorf("invalid previous hash. Got %x, expected %x", block.PreviousHash, lastBlock.Hash)
+	mined := make(map[string]bool)
+	for _, blk := range b.Ledger {
+		for _, tx := range blk.Transactions {
+			markMined(mined, tx)
+		}
 	}
 
-	// 2. verifica o pow
-	pow := NewProofOfWork(block)
-	if !pow.Validate() {
-		return errors.New("invalid proof of work")
+	return validateBlock(block, lastBlock, len(b.Ledger), func(txID string) bool { return mined[txID] }, b.nodeKeys, b.buildState(false), time.Now())
+}
+
+// troca a cadeia local por uma mais longa vinda de outro nó (sincronização)
+// valida bloco a bloco a partir do genesis antes de trocar
+func (b *Blockchain) ReplaceChain(ledger []*Block) error {
+	if len(ledger) == 0 {
+		return errors.New("empty chain")
 	}
 
-	// 3. verifica assinaturas das transacoes
-	for _, tx := range block.Transactions {
-		// ignora genesis
-		if tx.Type == "GENESIS" {
-			continue
+	b.MX.Lock()
+	defer b.MX.Unlock()
+
+	if len(ledger) <= len(b.Ledger) {
+		return fmt.Errorf("chain is not longer than local. Got %d, have %d", len(ledger), len(b.Ledger))
+	}
+	if ledger[0] == nil || !bytes.Equal(ledger[0].Hash, b.Ledger[0].Hash) {
+		return errors.New("genesis mismatch")
+	}
+
+	// valida bloco a bloco; anti-replay e estado olham só o que veio antes na cadeia nova
+	now := time.Now()
+	chainTxs := make(map[string]bool)
+	inChain := func(txID string) bool { return chainTxs[txID] }
+	state := b.newState()
+	for i := 1; i < len(ledger); i++ {
+		if err := validateBlock(ledger[i], ledger[i-1], i, inChain, b.nodeKeys, state, now); err != nil {
+			return fmt.Errorf("block %d: %w", i, err)
+		}
+		for _, tx := range ledger[i].Transactions {
+			markMined(chainTxs, tx)
 		}
+	}
+
+	// ponto de fork: primeiro bloco da cadeia nova que não estava na antiga
+	fork := 1
+	for fork < len(b.Ledger) && bytes.Equal(b.Ledger[fork].Hash, ledger[fork].Hash) {
+		fork++
+	}
+
+	oldLedger := b.Ledger
+	b.Ledger = ledger
+	b.Height = len(ledger)
+	b.explorer.rebuild(ledger)
+	b.publish(ChainEvent{
+		Kind:    ChainReplaced,
+		Height:  len(ledger) - 1,
+		Block:   ledger[len(ledger)-1],
+		Fork:    fork,
+		Adopted: append([]*Block(nil), ledger[fork:]...),
+	})
 
-		if !VerifySignature(tx.PublicKey, tx.UserData, tx.Signature) {
-			return fmt.Errorf("block contains invalid transaction signature: %s", tx.ID)
+	// tira da mempool o que já entrou na cadeia nova
+	mined := make(map[string]bool)
+	for _, block := range ledger {
+		for _, tx := range block.Transactions {
+			markMined(mined, tx)
 		}
 	}
+	b.MPool = b.pendingAfter(mined)
+	b.trackOrphans(oldLedger, mined)
+	b.pruneTracked(mined)
 
+	slog.Info("Blockchain: cadeia substituída", "height", b.Height)
 	return nil
 }
 
+// mempool sem o que foi minerado (chamar com o MX travado)
+// tx com o mesmo request de uma minerada (outro id) não pode mais entrar em bloco, vira descartada
+func (b *Blockchain) pendingAfter(mined map[string]bool) []models.Transaction {
+	newPool := []models.Transaction{}
+	for _, tx := range b.MPool {
+		switch {
+		case mined[tx.ID]:
+		case mined[requestKey(&tx)]:
+			b.track(tx.ID, models.TxStatusDropped, "request already mined in another transaction")
+		default:
+			newPool = append(newPool, tx)
+		}
+	}
+	return newPool
+}
+
 // confere se a tx ja existe na mpool ou no ledger
 func (b *Blockchain) AntiReplay(txID string) bool {
 	// olha na mempool
@@ -173,16 +327,70 @@ func (b *Blockchain) AntiReplay(txID string) bool {
 	return true
 }
 
+// tamanho atual da cadeia (genesis conta)
+func (b *Blockchain) CurrentHeight() int {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+	return len(b.Ledger)
+}
+
+// true se a tx já está na mempool ou no ledger
+func (b *Blockchain) HasTransaction(txID string) bool {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+	return !b.AntiReplay(txID)
+}
+
+// true se o bloco (pelo hash) já está na cadeia
+func (b *Blockchain) HasBlock(hash []byte) bool {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+
+	for i := len(b.Ledger) - 1; i >= 0; i-- {
+		if bytes.Equal(b.Ledger[i].Hash, hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// procura uma tx já minerada pelo id
+func (b *Blockchain) FindTransaction(txID string) (*models.Transaction, bool) {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+
+	for _, block := range b.Ledger {
+		for _, t := range block.Transactions {
+			if t.ID == txID {
+				return t, true
+			}
+		}
+	}
+	return nil, false
+}
+
 // checa se os dados batem com o tipo de transação
 func (b *Blockchain) ValidateFormat(tx models.Transaction) error {
+	return validateFormat(tx)
+}
+
+func validateFormat(tx models.Transaction) error {
 	var requiredLen int
 	switch tx.Type {
 	case models.TxPurchase: // [0]UserID, [1]CardID, [2]CardModel
 		requiredLen = 3
-	case models.TxTrade: // [0]U1, [1]U2, [2]C1, [3]C2
-		requiredLen = 4
-	case models.TxBattleResult: // [0]BattleID, [1]U1, [2]U2, [3]Winner
-		requiredLen = 4
+	case models.TxTrade: // [0]U1, [1]U2, [2]C1, [3]C2, [4]AceiteJSON
+		requiredLen = 5
+	case models.TxBattleResult: // [0]BattleID, [1]HostID, [2]Winner, [3]Meta, [4]CardsJSON, [5]Jogador1, [6]Jogador2
+		requiredLen = 7
+	case models.TxCraft: // [0]UserID, [1]BurnedIDs (json), [2]MintedCard (json)
+		requiredLen = 3
+	case models.TxMarketList: // [0]Seller, [1]CardID, [2]Price
+		requiredLen = 3
+	case models.TxMarketCancel, models.TxMarketBuy: // [0]UserID, [1]ListingID
+		requiredLen = 2
+	case models.TxCoinbase: // [0]MinerID, [1]Reward, [2]Height
+		requiredLen = 3
 	default:
 		return errors.New("unknown transaction type")
 	}
@@ -193,13 +401,15 @@ func (b *Blockchain) ValidateFormat(tx models.Transaction) error {
 	return nil
 }
 
-// loop principal pra monitorar canais (chamado pelo server)
-func (b *Blockchain) RunBlockchainLoop() {
+// loop principal pra monitorar canais (chamado pelo server), para quando o ctx é cancelado
+func (b *Blockchain) RunBlockchainLoop(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case task := <-b.IncomingBlocks:
 			// chegou bloco novo da rede
-			*b.StateChan <- 3 // manda sinal de CANCEL para quem tiver minerando agora
+			b.CancelMining() // manda sinal de CANCEL para quem tiver minerando agora
 
 			err := b.CheckNewBlock(task.Block)
 			if err != nil {
@@ -216,3 +426,14 @@ func (b *Blockchain) RunBlockchainLoop() {
 		}
 	}
 }
+
+// quanto esperar o pow pegar o sinal de cancelamento (ele olha o canal a cada nonce)
+const MiningCancelWait = 100 * time.Millisecond
+
+// interrompe a mineração em andamento, se tiver; sem ninguém minerando desiste depois do wait
+func (b *Blockchain) CancelMining() {
+	select {
+	case *b.StateChan <- Cancel:
+	case <-time.After(MiningCancelWait):
+	}
+}
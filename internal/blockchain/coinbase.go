@@ -0,0 +1,180 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// coinbase: primeira tx de todo bloco, diz qual servidor minerou e paga a recompensa
+// [0]MinerID, [1]Reward, [2]Height
+// UserData = Data + hash do bloco anterior, assinado com a chave do nó que minerou
+// (o hash anterior prende a coinbase na posição, não dá pra reaproveitar em outro bloco)
+
+var errNoMiner = errors.New("miner identity not configured")
+
+// chave pública registrada de um nó (no formato do elliptic.Marshal); false se não conhece
+// vem de fora (diretório de chaves do servidor), a blockchain só consulta
+type NodeKeyLookup func(nodeID string) ([]byte, bool)
+
+// configura o diretório de chaves usado pra conferir quem assinou a coinbase
+func (b *Blockchain) SetNodeKeys(lookup NodeKeyLookup) {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+	b.nodeKeys = lookup
+}
+
+// a chave que assinou é a registrada pro nó?
+func signedByNode(nodeKeys NodeKeyLookup, nodeID string, pub []byte) error {
+	if nodeKeys == nil {
+		return errors.New("node keys not configured")
+	}
+	registered, ok := nodeKeys(nodeID)
+	if !ok {
+		return fmt.Errorf("no key registered for %s", nodeID)
+	}
+	if !bytes.Equal(registered, pub) {
+		return fmt.Errorf("key is not the one registered for %s", nodeID)
+	}
+	return nil
+}
+
+// quem minera nesse nó
+type minerIdentity struct {
+	id  string
+	key *ecdsa.PrivateKey
+}
+
+// configura a identidade usada nas coinbases (id do servidor + chave do nó)
+func (b *Blockchain) SetMiner(id string, key *ecdsa.PrivateKey) {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+	b.miner = &minerIdentity{id: id, key: key}
+}
+
+// monta a coinbase do bloco na altura informada
+func newCoinbase(miner *minerIdentity, height int, prevHash []byte) (*models.Transaction, error) {
+	data := []string{miner.id, strconv.Itoa(models.BlockReward), strconv.Itoa(height)}
+	userData := append(append([]string{}, data...), hex.EncodeToString(prevHash))
+
+	sig, err := signData(miner.key, userData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Transaction{
+		ID:        fmt.Sprintf("coinbase-%d-%s", height, hex.EncodeToString(prevHash)),
+		Type:      models.TxCoinbase,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+		UserData:  userData,
+		PublicKey: elliptic.Marshal(elliptic.P256(), miner.key.PublicKey.X, miner.key.PublicKey.Y),
+		Signature: sig,
+	}, nil
+}
+
+// assina no mesmo formato que o VerifySignature espera (r e s com 32 bytes cada)
+func signData(key *ecdsa.PrivateKey, data []string) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(raw)
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return sig, nil
+}
+
+// regras da coinbase de um bloco na altura height
+func validateCoinbase(block *Block, height int, nodeKeys NodeKeyLookup) error {
+	cb := block.Transactions[0]
+	if cb.Type != models.TxCoinbase {
+		return ruleError(ErrCoinbaseMissing, "", "first transaction is %s", cb.Type)
+	}
+	for _, tx := range block.Transactions[1:] {
+		if tx.Type == models.TxCoinbase {
+			return ruleError(ErrCoinbaseExtra, tx.ID, "")
+		}
+	}
+
+	if len(cb.Data) < 3 || len(cb.UserData) < 4 || cb.Data[0] == "" {
+		return ruleError(ErrCoinbaseInvalid, cb.ID, "malformed data")
+	}
+	for i := 0; i < 3; i++ {
+		if cb.Data[i] != cb.UserData[i] {
+			return ruleError(ErrCoinbaseInvalid, cb.ID, "signed data does not match")
+		}
+	}
+	if cb.UserData[3] != hex.EncodeToString(block.PreviousHash) {
+		return ruleError(ErrCoinbaseInvalid, cb.ID, "signed for another block")
+	}
+	// sem isso qualquer chave assina dizendo ser outro servidor
+	if err := signedByNode(nodeKeys, cb.Data[0], cb.PublicKey); err != nil {
+		return ruleError(ErrUnknownSigner, cb.ID, "%v", err)
+	}
+
+	if reward, err := strconv.Atoi(cb.Data[1]); err != nil || reward != models.BlockReward {
+		return ruleError(ErrCoinbaseAmount, cb.ID, "got %s, expected %d", cb.Data[1], models.BlockReward)
+	}
+	if h, err := strconv.Atoi(cb.Data[2]); err != nil || h != height {
+		return ruleError(ErrCoinbaseHeight, cb.ID, "got %s, expected %d", cb.Data[2], height)
+	}
+	return nil
+}
+
+// estatísticas por minerador a partir das coinbases
+func (b *Blockchain) MinerStats() []models.MinerStats {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+
+	stats := make(map[string]*models.MinerStats)
+	for height, block := range b.Ledger {
+		if len(block.Transactions) == 0 || block.Transactions[0].Type != models.TxCoinbase {
+			continue
+		}
+		cb := block.Transactions[0]
+		if len(cb.Data) < 2 {
+			continue
+		}
+
+		st, ok := stats[cb.Data[0]]
+		if !ok {
+			st = &models.MinerStats{MinerID: cb.Data[0]}
+			stats[cb.Data[0]] = st
+		}
+		reward, _ := strconv.Atoi(cb.Data[1])
+		st.Blocks++
+		st.Rewards += reward
+		st.LastHeight = height
+		st.LastMinedAt = block.Timestamp
+		st.PublicKey = hex.EncodeToString(cb.PublicKey)
+	}
+
+	list := make([]models.MinerStats, 0, len(stats))
+	for _, st := range stats {
+		list = append(list, *st)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Blocks != list[j].Blocks {
+			return list[i].Blocks > list[j].Blocks
+		}
+		return list[i].MinerID < list[j].MinerID
+	})
+	return list
+}
@@ -0,0 +1,82 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"log/slog"
+	"sync"
+)
+
+// eventos da cadeia pra quem quiser reagir sem ficar fazendo polling no ledger
+// a entrega não bloqueia: se o buffer de um inscrito encher, o evento é descartado pra ele.
+// por isso quem precisa de tudo (ex: listener) deve usar o evento só como aviso e reler o ledger
+
+type ChainEventKind string
+
+const (
+	ChainBlockAdded ChainEventKind = "block_added"    // bloco novo no fim da cadeia
+	ChainReplaced   ChainEventKind = "chain_replaced" // cadeia trocada por uma mais longa
+	ChainTxAccepted ChainEventKind = "tx_accepted"    // tx entrou na mempool
+)
+
+type ChainEvent struct {
+	Kind    ChainEventKind
+	Height  int                 // altura do bloco (ou da ponta, no replace); 0 no tx_accepted
+	Block   *Block              // bloco adicionado / nova ponta
+	Tx      *models.Transaction // só no tx_accepted
+	Fork    int                 // só no replace: altura do primeiro bloco que mudou
+	Adopted []*Block            // só no replace: blocos da cadeia nova do Fork até a ponta
+}
+
+type Subscription struct {
+	C  <-chan ChainEvent
+	ch chan ChainEvent
+	id int
+	b  *Blockchain
+}
+
+type chainSubscribers struct {
+	subs   map[int]*Subscription
+	nextID int
+	mx     sync.Mutex
+}
+
+// se inscreve nos eventos da cadeia; buffer é o tamanho do canal
+func (b *Blockchain) Subscribe(buffer int) *Subscription {
+	b.subscribers.mx.Lock()
+	defer b.subscribers.mx.Unlock()
+
+	if b.subscribers.subs == nil {
+		b.subscribers.subs = make(map[int]*Subscription)
+	}
+	b.subscribers.nextID++
+	ch := make(chan ChainEvent, buffer)
+	sub := &Subscription{C: ch, ch: ch, id: b.subscribers.nextID, b: b}
+	b.subscribers.subs[sub.id] = sub
+	return sub
+}
+
+// cancela a inscrição e fecha o canal
+func (sub *Subscription) Close() {
+	subs := &sub.b.subscribers
+	subs.mx.Lock()
+	defer subs.mx.Unlock()
+
+	if _, ok := subs.subs[sub.id]; ok {
+		delete(subs.subs, sub.id)
+		close(sub.ch)
+	}
+}
+
+// entrega pra todos os inscritos sem bloquear (chamado com o MX travado)
+func (b *Blockchain) publish(ev ChainEvent) {
+	b.subscribers.mx.Lock()
+	defer b.subscribers.mx.Unlock()
+
+	for id, sub := range b.subscribers.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			slog.Warn("Blockchain: inscrito lento, evento descartado", "sub", id, "kind", ev.Kind)
+		}
+	}
+}
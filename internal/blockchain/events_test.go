@@ -0,0 +1,36 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReplaceChainPublishesBlocksFromFork(t *testing.T) {
+	bc := newTestChain()
+	genesis := bc.Ledger[0]
+
+	// cadeia local: genesis, b1, a2
+	b1 := newTestBlock(t, genesis, 1, signedTx(t, "tx1"))
+	a2 := newTestBlock(t, b1, 2, signedTx(t, "tx2"))
+	if err := bc.ReplaceChain([]*Block{genesis, b1, a2}); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+
+	sub := bc.Subscribe(4)
+	defer sub.Close()
+
+	// cadeia nova, mais longa, que diverge depois do b1
+	c2 := newTestBlock(t, b1, 2, signedTx(t, "tx3"))
+	c3 := newTestBlock(t, c2, 3, signedTx(t, "tx4"))
+	if err := bc.ReplaceChain([]*Block{genesis, b1, c2, c3}); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+
+	ev := <-sub.C
+	if ev.Kind != ChainReplaced || ev.Fork != 2 {
+		t.Fatalf("expected chain_replaced from height 2, got %s from %d", ev.Kind, ev.Fork)
+	}
+	if len(ev.Adopted) != 2 || !bytes.Equal(ev.Adopted[0].Hash, c2.Hash) || !bytes.Equal(ev.Adopted[1].Hash, c3.Hash) {
+		t.Fatalf("expected adopted blocks c2 and c3, got %d blocks", len(ev.Adopted))
+	}
+}
@@ -0,0 +1,352 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync"
+)
+
+// índices de leitura pro explorer
+// são atualizados junto com o ledger (AddBlock/ReplaceChain, com o MX travado) mas têm
+// lock próprio, então consultas não disputam o MX com mineração e validação.
+// blocos e txs não mudam depois de entrar na cadeia, então dá pra devolver os ponteiros
+// e o json é montado fora de qualquer lock
+
+type txLocation struct {
+	height int
+	index  int
+}
+
+type explorerIndex struct {
+	blocks   []*Block                            // cópia do ledger (altura = posição)
+	byHash   map[string]int                      // hash hex -> altura
+	txs      map[string]txLocation               // txID -> posição
+	byPlayer map[string][]string                 // jogador -> txIDs em ordem da cadeia
+	byType   map[models.TransactionType][]string // tipo -> txIDs
+	byCard   map[string][]string                 // cardID -> txIDs (proveniência)
+	miners   map[string]bool                     // ids que só aparecem como minerador
+	mx       sync.RWMutex
+}
+
+func newExplorerIndex() *explorerIndex {
+	idx := &explorerIndex{}
+	idx.reset()
+	return idx
+}
+
+// chamar com idx.mx travado
+func (idx *explorerIndex) reset() {
+	idx.blocks = nil
+	idx.byHash = make(map[string]int)
+	idx.txs = make(map[string]txLocation)
+	idx.byPlayer = make(map[string][]string)
+	idx.byType = make(map[models.TransactionType][]string)
+	idx.byCard = make(map[string][]string)
+	idx.miners = make(map[string]bool)
+}
+
+// refaz tudo a partir de uma cadeia (start e ReplaceChain)
+func (idx *explorerIndex) rebuild(ledger []*Block) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+
+	idx.reset()
+	for _, block := range ledger {
+		idx.appendLocked(block)
+	}
+}
+
+// bloco novo no fim da cadeia
+func (idx *explorerIndex) add(block *Block) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+	idx.appendLocked(block)
+}
+
+func (idx *explorerIndex) appendLocked(block *Block) {
+	height := len(idx.blocks)
+	idx.blocks = append(idx.blocks, block)
+	idx.byHash[hex.EncodeToString(block.Hash)] = height
+
+	for i, tx := range block.Transactions {
+		idx.txs[tx.ID] = txLocation{height: height, index: i}
+		idx.byType[tx.Type] = append(idx.byType[tx.Type], tx.ID)
+
+		players, cards := idx.participants(tx)
+		for _, p := range uniq(players) {
+			idx.byPlayer[p] = append(idx.byPlayer[p], tx.ID)
+		}
+		for _, c := range uniq(cards) {
+			idx.byCard[c] = append(idx.byCard[c], tx.ID)
+		}
+		if tx.Type == models.TxCoinbase && len(tx.Data) > 0 {
+			idx.miners[tx.Data[0]] = true
+		}
+	}
+}
+
+// quem e quais cartas aparecem numa tx (depende do tipo, ver ValidateFormat)
+func (idx *explorerIndex) participants(tx *models.Transaction) (players, cards []string) {
+	d := tx.Data
+	switch tx.Type {
+	case models.TxPurchase: // [0]UserID, [1]BoosterJSON
+		if len(d) >= 2 {
+			players = []string{d[0]}
+			var booster models.Booster
+			if json.Unmarshal([]byte(d[1]), &booster) == nil {
+				for _, c := range booster.Cards {
+					cards = append(cards, c.ID)
+				}
+			}
+		}
+	case models.TxTrade: // [0]U1, [1]U2, [2]C1, [3]C2, [4]Aceite
+		if len(d) >= 4 {
+			players = []string{d[0], d[1]}
+			cards = []string{d[2], d[3]}
+		}
+	case models.TxBattleResult: // [0]BattleID, [1]HostID, [2]Winner, [3]Meta, [4]CardsJSON, [5]J1, [6]J2
+		if len(d) >= 7 {
+			players = []string{d[5], d[6]}
+		}
+		if len(d) >= 5 {
+			json.Unmarshal([]byte(d[4]), &cards)
+		}
+	case models.TxCraft: // [0]UserID, [1]BurnedIDs, [2]MintedCard
+		if len(d) >= 3 {
+			players = []string{d[0]}
+			json.Unmarshal([]byte(d[1]), &cards)
+			var minted models.Tanque
+			if json.Unmarshal([]byte(d[2]), &minted) == nil {
+				cards = append(cards, minted.ID)
+			}
+		}
+	case models.TxMarketList: // [0]Seller, [1]CardID
+		if len(d) >= 2 {
+			players = []string{d[0]}
+			cards = []string{d[1]}
+		}
+	case models.TxMarketCancel, models.TxMarketBuy: // [0]UserID, [1]ListingID
+		if len(d) >= 2 {
+			players = []string{d[0]}
+			// vendedor e carta estão na tx que criou o anúncio
+			if listing := idx.txLocked(d[1]); listing != nil && len(listing.Data) >= 2 {
+				players = append(players, listing.Data[0])
+				cards = []string{listing.Data[1]}
+			}
+		}
+	case models.TxCoinbase: // [0]MinerID
+		if len(d) >= 1 {
+			players = []string{d[0]}
+		}
+	}
+	return players, cards
+}
+
+func (idx *explorerIndex) txLocked(txID string) *models.Transaction {
+	loc, ok := idx.txs[txID]
+	if !ok {
+		return nil
+	}
+	return idx.blocks[loc.height].Transactions[loc.index]
+}
+
+func uniq(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	out := list[:0:0]
+	for _, v := range list {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// --- consultas (só lock de leitura do índice) ---
+
+// página 1 = mais recentes primeiro
+// página além do fim volta vazia; compara antes de multiplicar, senão um ?page= enorme
+// estoura o (page-1)*limit e vira índice negativo
+func paginate(total, page, limit int) (from, to int) {
+	if page < 1 || limit < 1 || page-1 > total/limit {
+		return total, total
+	}
+	from = (page - 1) * limit
+	if from > total {
+		from = total
+	}
+	to = from + limit
+	if to > total {
+		to = total
+	}
+	return from, to
+}
+
+// resumo de um bloco (sem as txs), usado no explorer e nos eventos
+func BlockSummary(height int, block *Block) models.ExplorerBlock {
+	sum := models.ExplorerBlock{
+		Height:       height,
+		Hash:         hex.EncodeToString(block.Hash),
+		PreviousHash: hex.EncodeToString(block.PreviousHash),
+		Timestamp:    block.Timestamp,
+		Nonce:        block.Nonce,
+		TxCount:      len(block.Transactions),
+	}
+	if len(block.Transactions) > 0 && block.Transactions[0].Type == models.TxCoinbase && len(block.Transactions[0].Data) > 0 {
+		sum.Miner = block.Transactions[0].Data[0]
+	}
+	return sum
+}
+
+// blocos do mais novo pro mais velho
+func (b *Blockchain) ExplorerBlocks(page, limit int) models.ExplorerPage {
+	idx := b.explorer
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+
+	total := len(idx.blocks)
+	from, to := paginate(total, page, limit)
+	items := make([]models.ExplorerBlock, 0, to-from)
+	for i := from; i < to; i++ {
+		height := total - 1 - i
+		items = append(items, BlockSummary(height, idx.blocks[height]))
+	}
+	return models.ExplorerPage{Page: page, Limit: limit, Total: total, Items: items}
+}
+
+// bloco pela altura ou pelo hash (hex)
+func (b *Blockchain) ExplorerBlock(id string) (models.ExplorerBlockDetail, bool) {
+	idx := b.explorer
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+
+	height, ok := idx.byHash[id]
+	if !ok {
+		h, err := strconv.Atoi(id)
+		if err != nil || h < 0 || h >= len(idx.blocks) {
+			return models.ExplorerBlockDetail{}, false
+		}
+		height = h
+	}
+
+	block := idx.blocks[height]
+	return models.ExplorerBlockDetail{
+		ExplorerBlock: BlockSummary(height, block),
+		Transactions:  block.Transactions,
+	}, true
+}
+
+// tx minerada pelo id
+func (b *Blockchain) ExplorerTx(txID string) (models.ExplorerTx, bool) {
+	idx := b.explorer
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+
+	loc, ok := idx.txs[txID]
+	if !ok {
+		return models.ExplorerTx{}, false
+	}
+	return idx.locate(loc), true
+}
+
+func (idx *explorerIndex) locate(loc txLocation) models.ExplorerTx {
+	block := idx.blocks[loc.height]
+	return models.ExplorerTx{
+		Height:    loc.height,
+		BlockHash: hex.EncodeToString(block.Hash),
+		Index:     loc.index,
+		Tx:        block.Transactions[loc.index],
+	}
+}
+
+// página de uma lista de txIDs, mais recentes primeiro (ou em ordem da cadeia se oldestFirst)
+func (idx *explorerIndex) txPage(ids []string, page, limit int, oldestFirst bool) models.ExplorerPage {
+	total := len(ids)
+	from, to := paginate(total, page, limit)
+	items := make([]models.ExplorerTx, 0, to-from)
+	for i := from; i < to; i++ {
+		pos := total - 1 - i
+		if oldestFirst {
+			pos = i
+		}
+		items = append(items, idx.locate(idx.txs[ids[pos]]))
+	}
+	return models.ExplorerPage{Page: page, Limit: limit, Total: total, Items: items}
+}
+
+func (b *Blockchain) ExplorerTxsByPlayer(playerID string, page, limit int) models.ExplorerPage {
+	idx := b.explorer
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	return idx.txPage(idx.byPlayer[playerID], page, limit, false)
+}
+
+func (b *Blockchain) ExplorerTxsByType(txType models.TransactionType, page, limit int) models.ExplorerPage {
+	idx := b.explorer
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	return idx.txPage(idx.byType[txType], page, limit, false)
+}
+
+// proveniência de uma carta: da criação até agora, em ordem da cadeia
+func (b *Blockchain) ExplorerTxsByCard(cardID string, page, limit int) models.ExplorerPage {
+	idx := b.explorer
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+	return idx.txPage(idx.byCard[cardID], page, limit, true)
+}
+
+// mesma lista sem paginação (histórico de cartas do server)
+func (b *Blockchain) ExplorerCardTxs(cardID string) []models.ExplorerTx {
+	idx := b.explorer
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+
+	ids := idx.byCard[cardID]
+	txs := make([]models.ExplorerTx, 0, len(ids))
+	for _, id := range ids {
+		txs = append(txs, idx.locate(idx.txs[id]))
+	}
+	return txs
+}
+
+// resumo da cadeia
+func (b *Blockchain) ExplorerStats() models.ExplorerStats {
+	// mempool é o único dado que vem do MX, pega rápido e solta
+	b.MX.Lock()
+	mempool := len(b.MPool)
+	b.MX.Unlock()
+
+	idx := b.explorer
+	idx.mx.RLock()
+	defer idx.mx.RUnlock()
+
+	stats := models.ExplorerStats{
+		Height:      len(idx.blocks),
+		TotalTxs:    len(idx.txs),
+		TxsByType:   make(map[models.TransactionType]int),
+		Cards:       len(idx.byCard),
+		MempoolSize: mempool,
+	}
+	for t, ids := range idx.byType {
+		stats.TxsByType[t] = len(ids)
+	}
+	for p := range idx.byPlayer {
+		if !idx.miners[p] {
+			stats.Players++
+		}
+	}
+
+	if n := len(idx.blocks); n > 0 {
+		last := idx.blocks[n-1]
+		stats.LastBlockHash = hex.EncodeToString(last.Hash)
+		stats.LastBlockAt = last.Timestamp
+		// intervalo médio a partir do bloco 1 (genesis tem o horário de subida do nó)
+		if n > 2 {
+			stats.AvgBlockInterval = float64(last.Timestamp-idx.blocks[1].Timestamp) / float64(n-2)
+		}
+	}
+	return stats
+}
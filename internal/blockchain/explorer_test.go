@@ -0,0 +1,28 @@
+package blockchain
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	cases := []struct {
+		name               string
+		total, page, limit int
+		wantFrom, wantTo   int
+	}{
+		{"first page", 25, 1, 10, 0, 10},
+		{"last partial page", 25, 3, 10, 20, 25},
+		{"past the end", 25, 4, 10, 25, 25},
+		{"page that overflows the offset", 25, math.MaxInt, 50, 25, 25},
+		{"page just past overflow on 64 bits", 25, math.MaxInt/50 + 2, 50, 25, 25},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			from, to := paginate(tc.total, tc.page, tc.limit)
+			if from != tc.wantFrom || to != tc.wantTo {
+				t.Fatalf("paginate(%d, %d, %d) = %d, %d; want %d, %d", tc.total, tc.page, tc.limit, from, to, tc.wantFrom, tc.wantTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// validade dos requests assinados pelo cliente
+// o UserData de toda tx de jogador é [payload, timestamp, userID, tipo, requestID]
+// timestamp e requestID entram na assinatura, então um request capturado não serve
+// depois da janela nem duas vezes dentro dela (anti-replay por chave + requestID)
+
+const (
+	RequestMaxAge    = 2 * time.Minute  // request mais velho que isso é recusado na api
+	TxValidityWindow = 10 * time.Minute // tx que não entrou em bloco até aqui sai da mempool
+)
+
+var (
+	ErrTxExpired       = errors.New("transaction outside validity window")
+	ErrRequestReplay   = errors.New("request id already used by this key")
+	ErrRequestStale    = errors.New("request timestamp outside freshness window")
+	errMissingRequest  = errors.New("missing signed request id")
+	errInvalidSignedAt = errors.New("invalid signed timestamp")
+)
+
+// campos que o cliente assina, na ordem (a mesma do assinarRequest no client)
+func RequestSignedData(req models.TransactionRequest) []string {
+	return []string{
+		req.Payload,
+		fmt.Sprintf("%d", req.Timestamp), // timestamp como string
+		req.UserID,
+		string(req.Type),
+		req.RequestID,
+	}
+}
+
+// request recém assinado: nem velho demais nem do futuro, e com requestID
+func CheckRequestFreshness(req models.TransactionRequest, now time.Time) error {
+	if req.RequestID == "" {
+		return errMissingRequest
+	}
+	if req.Timestamp < now.Add(-RequestMaxAge).Unix() || req.Timestamp > now.Add(MaxFutureDrift).Unix() {
+		return fmt.Errorf("%w: signed at %d, now %d", ErrRequestStale, req.Timestamp, now.Unix())
+	}
+	return nil
+}
+
+// timestamp e requestID assinados de uma tx de jogador
+func txRequestMeta(tx *models.Transaction) (int64, string, error) {
+	if len(tx.UserData) < 5 || tx.UserData[4] == "" {
+		return 0, "", errMissingRequest
+	}
+	signedAt, err := strconv.ParseInt(tx.UserData[1], 10, 64)
+	if err != nil {
+		return 0, "", errInvalidSignedAt
+	}
+	return signedAt, tx.UserData[4], nil
+}
+
+// chave do anti-replay de request; vazia pra coinbase ou tx sem requestID
+// o prefixo separa das chaves de tx.ID nos mapas de "já minerado"
+func requestKey(tx *models.Transaction) string {
+	if tx.Type == models.TxCoinbase {
+		return ""
+	}
+	_, requestID, err := txRequestMeta(tx)
+	if err != nil {
+		return ""
+	}
+	return "req:" + hex.EncodeToString(tx.PublicKey) + ":" + requestID
+}
+
+// a tx tem que ter sido assinada dentro da janela em relação a ref (agora ou timestamp do bloco)
+func checkTxWindow(tx *models.Transaction, ref int64) error {
+	signedAt, _, err := txRequestMeta(tx)
+	if err != nil {
+		return ruleError(ErrTxFormat, tx.ID, "%v", err)
+	}
+	if limit := ref + int64(MaxFutureDrift/time.Second); signedAt > limit {
+		return ruleError(ErrTimestampFuture, tx.ID, "signed at %d, limit %d", signedAt, limit)
+	}
+	if oldest := ref - int64(TxValidityWindow/time.Second); signedAt < oldest {
+		return ruleError(ErrTxExpired, tx.ID, "signed at %d, oldest %d", signedAt, oldest)
+	}
+	return nil
+}
+
+// marca a tx (e o request dela) num mapa de "já minerado"
+func markMined(mined map[string]bool, tx *models.Transaction) {
+	mined[tx.ID] = true
+	if key := requestKey(tx); key != "" {
+		mined[key] = true
+	}
+}
+
+// o request já foi usado na mempool ou na cadeia? (chamar com o MX travado)
+// só precisa olhar os blocos da janela: um request repetido tem o mesmo timestamp assinado,
+// então a tx antiga está num bloco com timestamp >= agora - TxValidityWindow - MaxFutureDrift
+func (b *Blockchain) requestUsed(key string, now time.Time) bool {
+	if key == "" {
+		return false
+	}
+	for i := range b.MPool {
+		if requestKey(&b.MPool[i]) == key {
+			return true
+		}
+	}
+
+	oldest := now.Add(-TxValidityWindow - MaxFutureDrift).Unix()
+	for i := len(b.Ledger) - 1; i > 0 && b.Ledger[i].Timestamp >= oldest; i-- {
+		for _, tx := range b.Ledger[i].Transactions {
+			if requestKey(tx) == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// true se essa chave já mandou um request com esse id (mempool ou cadeia recente)
+func (b *Blockchain) HasRequest(req models.TransactionRequest) bool {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+
+	key := "req:" + hex.EncodeToString(req.PublicKey) + ":" + req.RequestID
+	return b.requestUsed(key, time.Now())
+}
+
+// tira da mempool as txs que passaram da janela sem serem mineradas
+func (b *Blockchain) ExpireMempool(now time.Time) int {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+	return b.expireMempool(now)
+}
+
+// chamar com o MX travado
+func (b *Blockchain) expireMempool(now time.Time) int {
+	kept := b.MPool[:0]
+	expired := 0
+	for _, tx := range b.MPool {
+		if err := checkTxWindow(&tx, now.Unix()); errors.Is(err, ErrTxExpired) {
+			b.track(tx.ID, models.TxStatusDropped, "expired before being mined")
+			slog.Warn("Blockchain: tx expirada removida da mempool", "txID", tx.ID)
+			expired++
+			continue
+		}
+		kept = append(kept, tx)
+	}
+	b.MPool = kept
+	return expired
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckNewBlockRejectsStaleOrReusedRequest(t *testing.T) {
+	bc := newTestChain()
+	genesis := bc.Ledger[0]
+	stale := time.Now().Add(-TxValidityWindow - time.Minute).Unix()
+
+	t.Run("expired request", func(t *testing.T) {
+		block := newTestBlock(t, genesis, 1, signedRequestTx(t, "tx1", "req1", stale))
+		expectRule(t, bc.CheckNewBlock(block), ErrTxExpired)
+	})
+
+	t.Run("same request twice in block", func(t *testing.T) {
+		now := time.Now().Unix()
+		block := newTestBlock(t, genesis, 1, signedRequestTx(t, "tx1", "req1", now), signedRequestTx(t, "tx2", "req1", now))
+		expectRule(t, bc.CheckNewBlock(block), ErrRequestReplay)
+	})
+
+	t.Run("request already in chain", func(t *testing.T) {
+		chain := newTestChain()
+		now := time.Now().Unix()
+		first := newTestBlock(t, chain.Ledger[0], 1, signedRequestTx(t, "tx1", "req1", now))
+		chain.AddBlock(first)
+		replay := newTestBlock(t, first, 2, signedRequestTx(t, "tx2", "req1", now))
+		expectRule(t, chain.CheckNewBlock(replay), ErrRequestReplay)
+	})
+}
+
+func TestAddTransactionEnforcesRequestWindow(t *testing.T) {
+	bc := newTestChain()
+	now := time.Now().Unix()
+
+	if err := bc.AddTransaction(*signedRequestTx(t, "tx1", "req1", now)); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	// mesmo request assinado, tx nova gerada pelo servidor
+	if err := bc.AddTransaction(*signedRequestTx(t, "tx2", "req1", now)); !errors.Is(err, ErrRequestReplay) {
+		t.Fatalf("expected request replay, got %v", err)
+	}
+	stale := time.Now().Add(-TxValidityWindow - time.Minute).Unix()
+	if err := bc.AddTransaction(*signedRequestTx(t, "tx3", "req3", stale)); !errors.Is(err, ErrTxExpired) {
+		t.Fatalf("expected expired, got %v", err)
+	}
+}
+
+func TestExpireMempoolDropsOldTransactions(t *testing.T) {
+	bc := newTestChain()
+	if err := bc.AddTransaction(*signedTx(t, "tx1")); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	if n := bc.ExpireMempool(time.Now()); n != 0 {
+		t.Fatalf("fresh tx expired: %d", n)
+	}
+	if n := bc.ExpireMempool(time.Now().Add(TxValidityWindow + time.Minute)); n != 1 {
+		t.Fatalf("expected 1 expired, got %d", n)
+	}
+	if len(bc.MPool) != 0 {
+		t.Fatalf("mempool not empty: %d", len(bc.MPool))
+	}
+	if st := bc.TxStatus("tx1"); st.Status != models.TxStatusDropped {
+		t.Fatalf("expected dropped, got %+v", st)
+	}
+}
@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// ledger + mempool em disco, gravados no desligamento e lidos na subida
+// na leitura tudo passa pela validação de novo (cadeia pelo ReplaceChain, txs pelo AddTransaction),
+// então um arquivo velho ou mexido não entra sem conferir
+
+type chainState struct {
+	Ledger []*Block             `json:"ledger"`
+	MPool  []models.Transaction `json:"mempool"`
+}
+
+// grava em arquivo temporário e renomeia, assim uma queda no meio não corrompe o anterior
+func (b *Blockchain) SaveState(path string) error {
+	b.MX.Lock()
+	state := chainState{
+		Ledger: append([]*Block(nil), b.Ledger...),
+		MPool:  append([]models.Transaction(nil), b.MPool...),
+	}
+	b.MX.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	slog.Info("Blockchain: estado gravado", "path", path, "height", len(state.Ledger), "mempool", len(state.MPool))
+	return nil
+}
+
+// carrega o que foi gravado pelo SaveState; sem arquivo não faz nada
+// txs que expiraram ou já estão na cadeia ficam de fora
+func (b *Blockchain) LoadState(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state chainState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid state file: %w", err)
+	}
+
+	if len(state.Ledger) > b.CurrentHeight() {
+		if err := b.ReplaceChain(state.Ledger); err != nil {
+			return fmt.Errorf("stored chain rejected: %w", err)
+		}
+	}
+
+	restored := 0
+	for _, tx := range state.MPool {
+		if err := b.AddTransaction(tx); err == nil {
+			restored++
+		}
+	}
+
+	slog.Info("Blockchain: estado carregado", "path", path, "height", b.CurrentHeight(), "mempool", restored, "mempoolDropped", len(state.MPool)-restored)
+	return nil
+}
@@ -0,0 +1,43 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"testing"
+)
+
+func TestSaveAndLoadState(t *testing.T) {
+	bc := newTestChain()
+	bc.SetMiner(testMiner.id, testKey)
+	if err := bc.AddTransaction(*signedTx(t, "tx1")); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	block, err := bc.MineBlock()
+	if err != nil {
+		t.Fatalf("mine: %v", err)
+	}
+	bc.AddBlock(block)
+	if err := bc.AddTransaction(*signedTx(t, "tx2")); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	path := t.TempDir() + "/state.json"
+	if err := bc.SaveState(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	restored := newTestChain()
+	if err := restored.LoadState(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if restored.CurrentHeight() != 2 || len(restored.MPool) != 1 || restored.MPool[0].ID != "tx2" {
+		t.Fatalf("unexpected state: height %d, mempool %d", restored.CurrentHeight(), len(restored.MPool))
+	}
+	if st := restored.TxStatus("tx1"); st.Status != models.TxStatusMined {
+		t.Fatalf("expected tx1 mined after load, got %s", st.Status)
+	}
+
+	// arquivo que não existe = começa do genesis
+	if err := New().LoadState(t.TempDir() + "/missing.json"); err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+}
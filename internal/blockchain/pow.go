@@ -11,7 +11,8 @@ import (
 )
 
 // dificuldade do pow (quanto maior, mais difícil de achar)
-const targetBits = 20 // deixei baixo pra testar rápido, em prod sobe pra uns 24
+// é var só pros testes poderem baixar; em runtime ninguém altera
+var targetBits = 20 // deixei baixo pra testar rápido, em prod sobe pra uns 24
 
 // constantes pra controlar o estado da mineracao
 const (
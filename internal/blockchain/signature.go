@@ -49,19 +49,15 @@ func VerifySignature(publicKeyBytes []byte, UserData []string, signature []byte)
 func VerifyTransactionRequestSignature(req models.TransactionRequest) bool {
 	// IMPORTANTE: a ordem aqui tem que ser EXATAMENTE a mesma que o cliente usou pra assinar
 	// se não o hash sai diferente e a verificação falha
-	dataToVerify := []string{
-		req.Payload,
-		fmt.Sprintf("%d", req.Timestamp), // timestamp como string
-		req.UserID,
-		string(req.Type),
-	}
+	dataToVerify := RequestSignedData(req)
 
 	// log pra debug se der erro de assinatura
 	slog.Info("Verificando assinatura",
 		"payload", req.Payload,
 		"timestamp", req.Timestamp,
 		"userID", req.UserID,
-		"type", req.Type)
+		"type", req.Type,
+		"requestID", req.RequestID)
 
 	// serializa
 	jsonData, err := json.Marshal(dataToVerify)
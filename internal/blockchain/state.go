@@ -0,0 +1,433 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"PlanoZ/internal/utils/cardDB"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrKeyMismatch     = errors.New("public key does not match the one bound to user")
+	ErrReservedAccount = errors.New("user id is reserved for node accounts")
+)
+
+// conta dos servidores no ledger (recompensa de mineração); jogador não pode usar esse prefixo,
+// senão um cliente que se registra como "server1" gastava as coinbases do nó
+const MinerAccountPrefix = "node:"
+
+func MinerAccount(minerID string) string {
+	return MinerAccountPrefix + minerID
+}
+
+// definição de um modelo no catálogo de cartas (cardDB); false se o modelo não existe
+// vem de fora (o cardVault.json do servidor), a blockchain só consulta
+type CardCatalog func(modelo string) (models.CardData, bool)
+
+// configura o catálogo de cartas usado pra conferir a carta fabricada no craft
+// todos os nós carregam o mesmo cardVault.json, então o estado sai igual em todos
+func (b *Blockchain) SetCardCatalog(catalog CardCatalog) {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+	b.catalog = catalog
+}
+
+// estado derivado do ledger: quem é dono de cada carta, saldos, mercado e a chave de cada jogador
+// é reconstruído do zero repassando as transações em ordem
+type LedgerState struct {
+	Cards    map[string]models.Tanque        // cardID -> carta com OwnerID atual
+	Balances map[string]int                  // userID -> tokens
+	Listings map[string]models.MarketListing // listingID -> anúncio ativo
+	Battles  map[string]bool                 // batalhas que já pagaram recompensa
+	Trades   map[string]bool                 // trade_ids já executados (o aceite não vale duas vezes)
+	Keys     map[string]string               // userID -> chave pública (hex) do primeiro request dele
+	catalog  CardCatalog                     // confere a carta fabricada no craft
+}
+
+func NewLedgerState() *LedgerState {
+	return &LedgerState{
+		Cards:    make(map[string]models.Tanque),
+		Balances: make(map[string]int),
+		Listings: make(map[string]models.MarketListing),
+		Battles:  make(map[string]bool),
+		Trades:   make(map[string]bool),
+		Keys:     make(map[string]string),
+	}
+}
+
+// aplica uma transação no estado
+// se a tx for inválida nesse estado retorna erro e não muda nada
+func (st *LedgerState) Apply(tx *models.Transaction) error {
+	// quem assinou tem que ser a chave já ligada ao userID (ou a primeira dele)
+	signer := txSigner(tx)
+	if signer != "" {
+		if err := st.CheckKey(signer, tx.PublicKey); err != nil {
+			return err
+		}
+	}
+
+	if err := st.apply(tx); err != nil {
+		return err
+	}
+	if signer != "" {
+		st.bindKey(signer, tx.PublicKey)
+	}
+	return nil
+}
+
+func (st *LedgerState) apply(tx *models.Transaction) error {
+	switch tx.Type {
+	case models.TxPurchase:
+		return st.applyPurchase(tx)
+	case models.TxTrade:
+		return st.applyTrade(tx)
+	case models.TxCraft:
+		return st.applyCraft(tx)
+	case models.TxBattleResult:
+		return st.applyBattleResult(tx)
+	case models.TxMarketList:
+		return st.applyMarketList(tx)
+	case models.TxMarketCancel:
+		return st.applyMarketCancel(tx)
+	case models.TxMarketBuy:
+		return st.applyMarketBuy(tx)
+	case models.TxCoinbase:
+		return st.applyCoinbase(tx)
+	}
+	// genesis não mexe em nada
+	return nil
+}
+
+// userID que assinou a tx (UserData[2] dos requests do cliente)
+// coinbase e BR são assinados pela chave do nó, não de jogador
+func txSigner(tx *models.Transaction) string {
+	if tx.Type == models.TxCoinbase || tx.Type == models.TxBattleResult || len(tx.UserData) < 3 {
+		return ""
+	}
+	return tx.UserData[2]
+}
+
+// o userID fica preso à chave do primeiro request dele que entrou no ledger,
+// senão qualquer um assina com a própria chave dizendo ser outro jogador
+func (st *LedgerState) CheckKey(userID string, pub []byte) error {
+	if strings.HasPrefix(userID, MinerAccountPrefix) {
+		return fmt.Errorf("%w: %s", ErrReservedAccount, userID)
+	}
+	bound, ok := st.Keys[userID]
+	if ok && bound != hex.EncodeToString(pub) {
+		return fmt.Errorf("%w: %s", ErrKeyMismatch, userID)
+	}
+	return nil
+}
+
+func (st *LedgerState) bindKey(userID string, pub []byte) {
+	if _, ok := st.Keys[userID]; !ok {
+		st.Keys[userID] = hex.EncodeToString(pub)
+	}
+}
+
+// [0]UserID, [1]BoosterJSON
+func (st *LedgerState) applyPurchase(tx *models.Transaction) error {
+	if len(tx.Data) < 2 {
+		return errors.New("invalid purchase data")
+	}
+
+	var booster models.Booster
+	if err := json.Unmarshal([]byte(tx.Data[1]), &booster); err != nil {
+		return fmt.Errorf("invalid booster: %w", err)
+	}
+
+	for _, card := range booster.Cards {
+		if _, exists := st.Cards[card.ID]; exists {
+			return fmt.Errorf("card %s already minted", card.ID)
+		}
+	}
+	for _, card := range booster.Cards {
+		card.OwnerID = tx.Data[0]
+		st.Cards[card.ID] = card
+	}
+	return nil
+}
+
+// [0]U1, [1]U2, [2]C1, [3]C2, [4]AceiteJSON
+// só troca com o aceite assinado do U2 (ver trade.go), senão qualquer um pegava a carta dos outros
+func (st *LedgerState) applyTrade(tx *models.Transaction) error {
+	tradeID, accept, err := checkTradeAgreement(tx)
+	if err != nil {
+		return err
+	}
+	if st.Trades[tradeID] {
+		return fmt.Errorf("trade %s already executed", tradeID)
+	}
+
+	u1, u2, c1, c2 := tx.Data[0], tx.Data[1], tx.Data[2], tx.Data[3]
+	if err := st.CheckKey(u2, accept.PublicKey); err != nil {
+		return err
+	}
+	if err := st.checkOwner(u1, c1); err != nil {
+		return err
+	}
+	if err := st.checkOwner(u2, c2); err != nil {
+		return err
+	}
+
+	card1, card2 := st.Cards[c1], st.Cards[c2]
+	card1.OwnerID, card2.OwnerID = u2, u1
+	st.Cards[c1], st.Cards[c2] = card1, card2
+	st.Trades[tradeID] = true
+	st.bindKey(u2, accept.PublicKey)
+	return nil
+}
+
+// [0]UserID, [1]BurnedIDs, [2]MintedCard
+func (st *LedgerState) applyCraft(tx *models.Transaction) error {
+	if len(tx.Data) < 3 {
+		return errors.New("invalid craft data")
+	}
+
+	userID := tx.Data[0]
+	var burned []string
+	if err := json.Unmarshal([]byte(tx.Data[1]), &burned); err != nil {
+		return fmt.Errorf("invalid burned list: %w", err)
+	}
+	var minted models.Tanque
+	if err := json.Unmarshal([]byte(tx.Data[2]), &minted); err != nil {
+		return fmt.Errorf("invalid minted card: %w", err)
+	}
+
+	if len(burned) != cardDB.CRAFT_COST {
+		return fmt.Errorf("craft requires %d cards, got %d", cardDB.CRAFT_COST, len(burned))
+	}
+
+	// todas têm que ser do jogador, distintas e do mesmo modelo
+	seen := make(map[string]bool)
+	var modelo, raridade string
+	for _, id := range burned {
+		if seen[id] {
+			return fmt.Errorf("card %s burned twice", id)
+		}
+		seen[id] = true
+
+		if err := st.checkOwner(userID, id); err != nil {
+			return err
+		}
+		card := st.Cards[id]
+		if modelo == "" {
+			modelo, raridade = card.Modelo, card.Raridade
+		} else if card.Modelo != modelo {
+			return errors.New("burned cards are not duplicates")
+		}
+	}
+
+	next, ok := cardDB.NextRarity(raridade)
+	if !ok {
+		return fmt.Errorf("rarity %s cannot be crafted up", raridade)
+	}
+	if minted.Raridade != next {
+		return fmt.Errorf("minted card must be %s, got %s", next, minted.Raridade)
+	}
+	// a carta nova vem do servidor, sem assinatura: modelo e status têm que ser os do catálogo
+	if err := st.checkCatalog(minted); err != nil {
+		return err
+	}
+	if _, exists := st.Cards[minted.ID]; exists {
+		return fmt.Errorf("card %s already minted", minted.ID)
+	}
+
+	for _, id := range burned {
+		delete(st.Cards, id)
+	}
+	minted.OwnerID = userID
+	st.Cards[minted.ID] = minted
+	return nil
+}
+
+// a carta é igual à definição do modelo dela no catálogo
+func (st *LedgerState) checkCatalog(card models.Tanque) error {
+	if st.catalog == nil {
+		return errors.New("card catalog not configured")
+	}
+	def, ok := st.catalog(card.Modelo)
+	if !ok {
+		return fmt.Errorf("model %s not in catalog", card.Modelo)
+	}
+	if card.Raridade != def.Raridade || card.Categoria != def.Categoria || card.Vida != def.Vida ||
+		card.Ataque != def.Ataque || card.Blindagem != def.Blindagem || card.Velocidade != def.Velocidade ||
+		card.Habilidade != def.Habilidade || card.Edicao != def.Edicao {
+		return fmt.Errorf("card %s does not match catalog entry for %s", card.ID, card.Modelo)
+	}
+	return nil
+}
+
+// [0]BattleID, [1]HostID, [2]Winner (vazio = empate)
+// o BR vem assinado pelo host (ver battle.go), e a recompensa só sai uma vez por batalha
+func (st *LedgerState) applyBattleResult(tx *models.Transaction) error {
+	if len(tx.Data) < 3 {
+		return errors.New("invalid battle data")
+	}
+
+	battleID, winner := tx.Data[0], tx.Data[2]
+	if st.Battles[battleID] {
+		return fmt.Errorf("battle %s already rewarded", battleID)
+	}
+	st.Battles[battleID] = true
+	if winner != "" {
+		st.Balances[winner] += models.BattleReward
+	}
+	return nil
+}
+
+// [0]MinerID, [1]Reward (vai pra conta MinerAccount do servidor)
+func (st *LedgerState) applyCoinbase(tx *models.Transaction) error {
+	if len(tx.Data) < 2 {
+		return errors.New("invalid coinbase data")
+	}
+	reward, err := strconv.Atoi(tx.Data[1])
+	if err != nil || reward != models.BlockReward {
+		return fmt.Errorf("invalid coinbase reward %q", tx.Data[1])
+	}
+	st.Balances[MinerAccount(tx.Data[0])] += reward
+	return nil
+}
+
+// [0]Seller, [1]CardID, [2]Price
+func (st *LedgerState) applyMarketList(tx *models.Transaction) error {
+	if len(tx.Data) < 3 {
+		return errors.New("invalid listing data")
+	}
+
+	seller, cardID := tx.Data[0], tx.Data[1]
+	price, err := strconv.Atoi(tx.Data[2])
+	if err != nil || price <= 0 {
+		return fmt.Errorf("invalid price %q", tx.Data[2])
+	}
+	if err := st.checkOwner(seller, cardID); err != nil {
+		return err
+	}
+
+	st.Listings[tx.ID] = models.MarketListing{
+		ID:        tx.ID,
+		SellerID:  seller,
+		Card:      st.Cards[cardID],
+		Price:     price,
+		Timestamp: tx.Timestamp,
+	}
+	return nil
+}
+
+// [0]Seller, [1]ListingID
+func (st *LedgerState) applyMarketCancel(tx *models.Transaction) error {
+	if len(tx.Data) < 2 {
+		return errors.New("invalid cancel data")
+	}
+
+	listing, ok := st.Listings[tx.Data[1]]
+	if !ok {
+		return fmt.Errorf("listing %s not found", tx.Data[1])
+	}
+	if listing.SellerID != tx.Data[0] {
+		return errors.New("only the seller can cancel a listing")
+	}
+
+	delete(st.Listings, listing.ID)
+	return nil
+}
+
+// [0]Buyer, [1]ListingID
+func (st *LedgerState) applyMarketBuy(tx *models.Transaction) error {
+	if len(tx.Data) < 2 {
+		return errors.New("invalid buy data")
+	}
+
+	buyer := tx.Data[0]
+	listing, ok := st.Listings[tx.Data[1]]
+	if !ok {
+		return fmt.Errorf("listing %s not found", tx.Data[1])
+	}
+	if listing.SellerID == buyer {
+		return errors.New("seller cannot buy own listing")
+	}
+	if st.Balances[buyer] < listing.Price {
+		return fmt.Errorf("insufficient balance: has %d, needs %d", st.Balances[buyer], listing.Price)
+	}
+
+	card := st.Cards[listing.Card.ID]
+	card.OwnerID = buyer
+	st.Cards[card.ID] = card
+
+	st.Balances[buyer] -= listing.Price
+	st.Balances[listing.SellerID] += listing.Price
+	delete(st.Listings, listing.ID)
+	return nil
+}
+
+// carta precisa existir, ser do jogador e não estar anunciada no mercado
+func (st *LedgerState) checkOwner(userID, cardID string) error {
+	card, ok := st.Cards[cardID]
+	if !ok {
+		return fmt.Errorf("card %s not found in ledger", cardID)
+	}
+	if card.OwnerID != userID {
+		return fmt.Errorf("card %s does not belong to %s", cardID, userID)
+	}
+	for _, listing := range st.Listings {
+		if listing.Card.ID == cardID {
+			return fmt.Errorf("card %s is listed on the market", cardID)
+		}
+	}
+	return nil
+}
+
+// lista as cartas de um jogador
+func (st *LedgerState) CardsOf(userID string) []models.Tanque {
+	cards := []models.Tanque{}
+	for _, card := range st.Cards {
+		if card.OwnerID == userID {
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
+
+// reconstrói o estado a partir do ledger
+// txs que não se aplicam (ex, carta já queimada) são ignoradas, igual em todos os nós
+func (b *Blockchain) State() *LedgerState {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+	return b.buildState(false)
+}
+
+// igual ao State, mas também aplica o que está na mempool
+// usado pra validar tx nova sem deixar gastar a mesma carta duas vezes
+func (b *Blockchain) PendingState() *LedgerState {
+	b.MX.Lock()
+	defer b.MX.Unlock()
+	return b.buildState(true)
+}
+
+// estado vazio com o catálogo da cadeia
+func (b *Blockchain) newState() *LedgerState {
+	st := NewLedgerState()
+	st.catalog = b.catalog
+	return st
+}
+
+// chamar com o MX travado
+func (b *Blockchain) buildState(withMempool bool) *LedgerState {
+	st := b.newState()
+	for _, block := range b.Ledger {
+		for _, tx := range block.Transactions {
+			st.Apply(tx)
+		}
+	}
+	if withMempool {
+		for i := range b.MPool {
+			st.Apply(&b.MPool[i])
+		}
+	}
+	return st
+}
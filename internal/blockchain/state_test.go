@@ -0,0 +1,191 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"PlanoZ/internal/utils/cardDB"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLedgerStateBindsUserToFirstKey(t *testing.T) {
+	st := NewLedgerState()
+	if err := st.Apply(signedTx(t, "tx1")); err != nil {
+		t.Fatalf("first purchase rejected: %v", err)
+	}
+
+	// mesma tx de player1, mas assinada com outra chave
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	forged := signedRequestTx(t, "tx2", "req-tx2", time.Now().Unix())
+	forged.PublicKey = elliptic.Marshal(elliptic.P256(), other.PublicKey.X, other.PublicKey.Y)
+	sig, err := signData(other, forged.UserData)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	forged.Signature = sig
+
+	if err := st.Apply(forged); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("expected %v, got %v", ErrKeyMismatch, err)
+	}
+	if err := st.Apply(signedTx(t, "tx3")); err != nil {
+		t.Fatalf("bound key rejected: %v", err)
+	}
+}
+
+// request assinado com a chave escolhida, do jeito que o cliente monta
+func signRequest(t *testing.T, key *ecdsa.PrivateKey, userID string, typ models.TransactionType, payload interface{}) models.TransactionRequest {
+	t.Helper()
+
+	raw, _ := json.Marshal(payload)
+	req := models.TransactionRequest{
+		Type:      typ,
+		UserID:    userID,
+		Timestamp: time.Now().Unix(),
+		Payload:   string(raw),
+		RequestID: fmt.Sprintf("req-%s-%d", userID, time.Now().UnixNano()),
+		PublicKey: elliptic.Marshal(elliptic.P256(), key.PublicKey.X, key.PublicKey.Y),
+	}
+	sig, err := signData(key, RequestSignedData(req))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	req.Signature = sig
+	return req
+}
+
+// tx de jogador em cima do request (mesmo formato que o servidor monta)
+func requestTx(id string, req models.TransactionRequest, data ...string) *models.Transaction {
+	return &models.Transaction{
+		ID:        id,
+		Type:      req.Type,
+		Timestamp: req.Timestamp,
+		Data:      data,
+		UserData:  RequestSignedData(req),
+		PublicKey: req.PublicKey,
+		Signature: req.Signature,
+	}
+}
+
+func TestTradeRequiresCounterpartyAcceptance(t *testing.T) {
+	key2, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	st := NewLedgerState()
+	for i, owner := range []struct {
+		key  *ecdsa.PrivateKey
+		user string
+		card string
+	}{{testKey, "player1", "c1"}, {key2, "player2", "c2"}} {
+		booster, _ := json.Marshal(models.Booster{Cards: []models.Tanque{{ID: owner.card}}})
+		req := signRequest(t, owner.key, owner.user, models.TxPurchase, models.PurchasePayload{})
+		if err := st.Apply(requestTx(fmt.Sprintf("buy%d", i), req, owner.user, string(booster), "BOOSTER_PACK")); err != nil {
+			t.Fatalf("purchase: %v", err)
+		}
+	}
+
+	offerTerms := models.TradePayload{TradeID: "t1", UserTarget: "player2", CardMy: "c1", CardTarget: "c2"}
+	acceptTerms := models.TradePayload{TradeID: "t1", UserTarget: "player1", CardMy: "c2", CardTarget: "c1"}
+	trade := func(id string, accept models.TransactionRequest) *models.Transaction {
+		offer := offerTerms
+		offer.Accept = &accept
+		acceptJson, _ := json.Marshal(accept)
+		req := signRequest(t, testKey, "player1", models.TxTrade, offer)
+		return requestTx(id, req, "player1", "player2", "c1", "c2", string(acceptJson))
+	}
+
+	// aceite assinado por outra chave, e aceite de termos diferentes
+	forged := signRequest(t, testKey, "player2", models.TxTrade, acceptTerms)
+	if err := st.Apply(trade("td1", forged)); !errors.Is(err, ErrKeyMismatch) {
+		t.Fatalf("expected %v, got %v", ErrKeyMismatch, err)
+	}
+	otherTerms := acceptTerms
+	otherTerms.CardTarget = "c9"
+	if err := st.Apply(trade("td2", signRequest(t, key2, "player2", models.TxTrade, otherTerms))); !errors.Is(err, ErrTradeNotAccepted) {
+		t.Fatalf("expected %v, got %v", ErrTradeNotAccepted, err)
+	}
+	if st.Cards["c2"].OwnerID != "player2" {
+		t.Fatal("card moved without acceptance")
+	}
+
+	accepted := signRequest(t, key2, "player2", models.TxTrade, acceptTerms)
+	if err := st.Apply(trade("td3", accepted)); err != nil {
+		t.Fatalf("accepted trade rejected: %v", err)
+	}
+	if st.Cards["c1"].OwnerID != "player2" || st.Cards["c2"].OwnerID != "player1" {
+		t.Fatalf("cards not swapped: %+v", st.Cards)
+	}
+	if err := st.Apply(trade("td4", accepted)); err == nil {
+		t.Fatal("acceptance reused")
+	}
+}
+
+func TestCraftMintsOnlyCatalogCards(t *testing.T) {
+	catalog := map[string]models.CardData{
+		"M1": {Modelo: "M1", Raridade: models.RarityCommon, Vida: 10, Ataque: 5},
+		"M2": {Modelo: "M2", Raridade: models.RarityUncommon, Vida: 20, Ataque: 8},
+	}
+	st := NewLedgerState()
+	st.catalog = func(modelo string) (models.CardData, bool) {
+		def, ok := catalog[modelo]
+		return def, ok
+	}
+
+	var burned []string
+	var cards []models.Tanque
+	for i := 0; i < cardDB.CRAFT_COST; i++ {
+		id := fmt.Sprintf("c%d", i)
+		burned = append(burned, id)
+		cards = append(cards, models.Tanque{ID: id, Modelo: "M1", Raridade: models.RarityCommon, Vida: 10, Ataque: 5})
+	}
+	booster, _ := json.Marshal(models.Booster{Cards: cards})
+	buy := signRequest(t, testKey, "player1", models.TxPurchase, models.PurchasePayload{})
+	if err := st.Apply(requestTx("buy", buy, "player1", string(booster), "BOOSTER_PACK")); err != nil {
+		t.Fatalf("purchase: %v", err)
+	}
+
+	craft := func(id string, minted models.Tanque) error {
+		burnedJson, _ := json.Marshal(burned)
+		mintedJson, _ := json.Marshal(minted)
+		req := signRequest(t, testKey, "player1", models.TxCraft, models.CraftPayload{CardIDs: burned})
+		return st.Apply(requestTx(id, req, "player1", string(burnedJson), string(mintedJson)))
+	}
+
+	// raridade certa, mas status inflados ou modelo fora do catálogo
+	boosted := models.Tanque{ID: "new", Modelo: "M2", Raridade: models.RarityUncommon, Vida: 999, Ataque: 8}
+	if err := craft("cf1", boosted); err == nil {
+		t.Fatal("craft with inflated stats accepted")
+	}
+	unknown := models.Tanque{ID: "new", Modelo: "X", Raridade: models.RarityUncommon, Vida: 20, Ataque: 8}
+	if err := craft("cf2", unknown); err == nil {
+		t.Fatal("craft with unknown model accepted")
+	}
+	if _, ok := st.Cards["c0"]; !ok {
+		t.Fatal("cards burned by a rejected craft")
+	}
+
+	if err := craft("cf3", models.Tanque{ID: "new", Modelo: "M2", Raridade: models.RarityUncommon, Vida: 20, Ataque: 8}); err != nil {
+		t.Fatalf("catalog craft rejected: %v", err)
+	}
+	if st.Cards["new"].OwnerID != "player1" {
+		t.Fatal("crafted card not minted")
+	}
+}
+
+func TestPlayerCannotUseMinerAccount(t *testing.T) {
+	st := NewLedgerState()
+	cb := coinbaseTx(t, Genesis(), 1, models.BlockReward)
+	if err := st.Apply(cb); err != nil {
+		t.Fatalf("coinbase: %v", err)
+	}
+	if st.Balances[MinerAccount(testMiner.id)] != models.BlockReward || st.Balances[testMiner.id] != 0 {
+		t.Fatalf("reward not in miner account: %+v", st.Balances)
+	}
+
+	req := signRequest(t, testKey, MinerAccount(testMiner.id), models.TxMarketList, models.MarketListPayload{})
+	if err := st.Apply(requestTx("ml1", req, MinerAccount(testMiner.id), "c1", "5")); !errors.Is(err, ErrReservedAccount) {
+		t.Fatalf("expected %v, got %v", ErrReservedAccount, err)
+	}
+}
@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"errors"
+	"time"
+)
+
+// acompanha o que aconteceu com cada tx que passou por esse nó
+// mined sai do índice do explorer; aqui só fica o que não está na cadeia
+// (pendente, recusada ou descartada), protegido pelo MX
+
+const TxTrackerTTL = time.Hour // recusadas/descartadas somem do tracker depois disso
+
+var errDuplicateTx = errors.New("duplicated transaction")
+
+type txRecord struct {
+	status    models.TxStatus
+	reason    string
+	updatedAt int64
+}
+
+// chamar com o MX travado
+func (b *Blockchain) track(txID string, status models.TxStatus, reason string) {
+	if b.tracked == nil {
+		b.tracked = make(map[string]*txRecord)
+	}
+	b.tracked[txID] = &txRecord{status: status, reason: reason, updatedAt: time.Now().Unix()}
+}
+
+// tira do tracker o que já foi minerado e os finais antigos (chamar com o MX travado)
+func (b *Blockchain) pruneTracked(mined map[string]bool) {
+	limit := time.Now().Add(-TxTrackerTTL).Unix()
+	for id, rec := range b.tracked {
+		if mined[id] || (rec.status != models.TxStatusPending && rec.updatedAt < limit) {
+			delete(b.tracked, id)
+		}
+	}
+}
+
+// txs que estavam na cadeia antiga e não estão na nova nem voltaram pra mempool
+// (chamar com o MX travado, depois de trocar o ledger)
+func (b *Blockchain) trackOrphans(oldLedger []*Block, mined map[string]bool) {
+	inPool := make(map[string]bool, len(b.MPool))
+	for _, tx := range b.MPool {
+		inPool[tx.ID] = true
+	}
+	for _, block := range oldLedger {
+		for _, tx := range block.Transactions {
+			if !mined[tx.ID] && !inPool[tx.ID] {
+				b.track(tx.ID, models.TxStatusDropped, "orphaned by chain reorganization")
+			}
+		}
+	}
+}
+
+// situação atual de uma tx nesse nó
+// MX antes do índice (mesma ordem do AddBlock), assim uma tx não "some" entre a mempool e o bloco
+func (b *Blockchain) TxStatus(txID string) models.TxStatusResponse {
+	resp := models.TxStatusResponse{TxID: txID, Status: models.TxStatusUnknown}
+
+	b.MX.Lock()
+	defer b.MX.Unlock()
+
+	// 1. minerada
+	idx := b.explorer
+	idx.mx.RLock()
+	loc, mined := idx.txs[txID]
+	if mined {
+		located := idx.locate(loc)
+		resp.Status = models.TxStatusMined
+		resp.Height = located.Height
+		resp.BlockHash = located.BlockHash
+		resp.Confirmations = len(idx.blocks) - located.Height
+		resp.UpdatedAt = idx.blocks[loc.height].Timestamp
+	}
+	idx.mx.RUnlock()
+	if mined {
+		return resp
+	}
+
+	// 2. mempool
+	rec := b.tracked[txID]
+	for _, tx := range b.MPool {
+		if tx.ID == txID {
+			resp.Status = models.TxStatusPending
+			if rec != nil {
+				resp.UpdatedAt = rec.updatedAt
+			}
+			return resp
+		}
+	}
+
+	// 3. recusada/descartada
+	if rec != nil && rec.status != models.TxStatusPending {
+		resp.Status = rec.status
+		resp.Reason = rec.reason
+		resp.UpdatedAt = rec.updatedAt
+	}
+	return resp
+}
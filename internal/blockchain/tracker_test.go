@@ -0,0 +1,48 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"encoding/hex"
+	"testing"
+)
+
+func TestTxStatusLifecycle(t *testing.T) {
+	bc := newTestChain()
+	bc.SetMiner(testMiner.id, testKey)
+
+	if st := bc.TxStatus("tx1"); st.Status != models.TxStatusUnknown {
+		t.Fatalf("expected unknown, got %s", st.Status)
+	}
+
+	if err := bc.AddTransaction(*signedTx(t, "tx1")); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if st := bc.TxStatus("tx1"); st.Status != models.TxStatusPending {
+		t.Fatalf("expected pending, got %s", st.Status)
+	}
+
+	block, err := bc.MineBlock()
+	if err != nil {
+		t.Fatalf("mine: %v", err)
+	}
+	bc.AddBlock(block)
+
+	st := bc.TxStatus("tx1")
+	if st.Status != models.TxStatusMined || st.Height != 1 || st.Confirmations != 1 || st.BlockHash != hex.EncodeToString(block.Hash) {
+		t.Fatalf("unexpected mined status: %+v", st)
+	}
+
+	bc.AddBlock(newTestBlock(t, block, 2, signedTx(t, "tx2")))
+	if st := bc.TxStatus("tx1"); st.Confirmations != 2 {
+		t.Fatalf("expected 2 confirmations, got %d", st.Confirmations)
+	}
+
+	bad := signedTx(t, "tx3")
+	bad.Signature[0] ^= 0xff
+	if err := bc.AddTransaction(*bad); err == nil {
+		t.Fatal("tx with bad signature accepted")
+	}
+	if st := bc.TxStatus("tx3"); st.Status != models.TxStatusRejected || st.Reason == "" {
+		t.Fatalf("expected rejected with reason, got %+v", st)
+	}
+}
@@ -0,0 +1,64 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// troca (TD): quem registra é o jogador 1, mas a carta do jogador 2 só sai com o aceite dele
+// o aceite é um request do jogador 2 (tipo TD) assinando os mesmos termos espelhados:
+// mesmo trade_id, user_target = jogador 1, card_my = carta dele, card_target = carta do outro
+// [0]U1, [1]U2, [2]C1, [3]C2, [4]AceiteJSON (TransactionRequest assinado pelo U2)
+
+var ErrTradeNotAccepted = errors.New("trade not accepted by counterparty")
+
+// confere que o U1 assinou os termos do Data e que o U2 aceitou os mesmos termos
+// retorna o trade_id e o aceite (pra ligar a chave do U2)
+func checkTradeAgreement(tx *models.Transaction) (string, models.TransactionRequest, error) {
+	var accept models.TransactionRequest
+	d, u := tx.Data, tx.UserData
+	if len(d) < 5 || len(u) < 4 {
+		return "", accept, errors.New("invalid trade data")
+	}
+	u1, u2, c1, c2 := d[0], d[1], d[2], d[3]
+	if u1 == u2 || c1 == c2 {
+		return "", accept, errors.New("trade needs two players and two cards")
+	}
+
+	// 1. o que o U1 assinou
+	var offer models.TradePayload
+	if err := json.Unmarshal([]byte(u[0]), &offer); err != nil {
+		return "", accept, fmt.Errorf("invalid trade payload: %w", err)
+	}
+	if u[2] != u1 || u[3] != string(models.TxTrade) || offer.TradeID == "" ||
+		offer.UserTarget != u2 || offer.CardMy != c1 || offer.CardTarget != c2 {
+		return "", accept, errors.New("trade data does not match signed offer")
+	}
+
+	// 2. o aceite do U2, sobre os mesmos termos vistos do lado dele
+	if err := json.Unmarshal([]byte(d[4]), &accept); err != nil {
+		return "", accept, fmt.Errorf("%w: %v", ErrTradeNotAccepted, err)
+	}
+	if accept.UserID != u2 || accept.Type != models.TxTrade || !verifyRequest(accept) {
+		return "", accept, fmt.Errorf("%w: missing signature of %s", ErrTradeNotAccepted, u2)
+	}
+	var terms models.TradePayload
+	if err := json.Unmarshal([]byte(accept.Payload), &terms); err != nil {
+		return "", accept, fmt.Errorf("%w: %v", ErrTradeNotAccepted, err)
+	}
+	if terms.TradeID != offer.TradeID || terms.UserTarget != u1 || terms.CardMy != c2 || terms.CardTarget != c1 {
+		return "", accept, fmt.Errorf("%w: terms differ", ErrTradeNotAccepted)
+	}
+	return offer.TradeID, accept, nil
+}
+
+// assinatura do request sem o log do VerifyTransactionRequestSignature
+// (o estado é reconstruído o tempo todo, não dá pra logar a cada replay)
+func verifyRequest(req models.TransactionRequest) bool {
+	if len(req.PublicKey) == 0 || len(req.Signature) == 0 {
+		return false
+	}
+	return VerifySignature(req.PublicKey, RequestSignedData(req), req.Signature)
+}
@@ -0,0 +1,193 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// regras de consenso que todo bloco vindo da rede precisa cumprir
+// cada regra tem um erro próprio, então quem chama consegue saber exatamente
+// o que falhou com errors.Is (ex: errors.Is(err, ErrHashMismatch))
+
+const (
+	MaxBlockTxs    = 50              // máximo de txs por bloco (o minerador também respeita)
+	MaxTxDataBytes = 32 * 1024       // soma de Data + UserData de uma tx
+	MaxFutureDrift = 2 * time.Minute // bloco/tx não pode vir muito do futuro
+)
+
+var (
+	ErrMalformedBlock  = errors.New("malformed block")
+	ErrPrevHash        = errors.New("invalid previous hash")
+	ErrHashMismatch    = errors.New("block hash does not match contents")
+	ErrProofOfWork     = errors.New("invalid proof of work")
+	ErrTimestampPast   = errors.New("timestamp before previous block")
+	ErrTimestampFuture = errors.New("timestamp too far in the future")
+	ErrEmptyBlock      = errors.New("block has no transactions")
+	ErrTooManyTxs      = errors.New("block has too many transactions")
+	ErrDuplicateTx     = errors.New("duplicate transaction in block")
+	ErrTxReplay        = errors.New("transaction already in chain")
+	ErrTxFormat        = errors.New("invalid transaction format")
+	ErrTxTooLarge      = errors.New("transaction too large")
+	ErrTxSignature     = errors.New("invalid transaction signature")
+	ErrCoinbaseMissing = errors.New("first transaction is not a coinbase")
+	ErrCoinbaseExtra   = errors.New("more than one coinbase in block")
+	ErrCoinbaseInvalid = errors.New("invalid coinbase")
+	ErrCoinbaseAmount  = errors.New("wrong coinbase reward")
+	ErrCoinbaseHeight  = errors.New("wrong coinbase height")
+	ErrUnknownSigner   = errors.New("signer key not registered for node")
+	ErrTxState         = errors.New("transaction invalid for ledger state")
+)
+
+// erro de validação: qual regra quebrou e, se for o caso, em qual tx
+type ValidationError struct {
+	Rule   error
+	TxID   string
+	Detail string
+}
+
+func (e *ValidationError) Error() string {
+	msg := e.Rule.Error()
+	if e.TxID != "" {
+		msg += " (tx " + e.TxID + ")"
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Rule
+}
+
+func ruleError(rule error, txID, format string, args ...interface{}) error {
+	return &ValidationError{Rule: rule, TxID: txID, Detail: fmt.Sprintf(format, args...)}
+}
+
+// valida o bloco em cima do anterior; height é a posição do bloco na cadeia
+// inChain diz se uma tx (ou request, ver requestKey) já foi minerada antes desse bloco (anti-replay)
+// nodeKeys diz a chave registrada de cada servidor (quem assina a coinbase e os resultados de batalha)
+// state é o estado do ledger até lastBlock: as txs são aplicadas nele em ordem (ele sai modificado),
+// então gasto duplo, saldo insuficiente ou carta de outro dono derrubam o bloco inteiro
+func validateBlock(block, lastBlock *Block, height int, inChain func(txID string) bool, nodeKeys NodeKeyLookup, state *LedgerState, now time.Time) error {
+	// 1. estrutura
+	if block == nil || len(block.Hash) == 0 {
+		return ruleError(ErrMalformedBlock, "", "missing hash")
+	}
+	if len(block.Transactions) == 0 {
+		return ruleError(ErrEmptyBlock, "", "")
+	}
+	if len(block.Transactions) > MaxBlockTxs {
+		return ruleError(ErrTooManyTxs, "", "got %d, max %d", len(block.Transactions), MaxBlockTxs)
+	}
+	for i, tx := range block.Transactions {
+		if tx == nil {
+			return ruleError(ErrMalformedBlock, "", "nil transaction at %d", i)
+		}
+	}
+
+	// 2. encaixa no bloco anterior
+	if !bytes.Equal(block.PreviousHash, lastBlock.Hash) {
+		return ruleError(ErrPrevHash, "", "got %x, expected %x", block.PreviousHash, lastBlock.Hash)
+	}
+
+	// 3. hash declarado bate com o conteúdo e cumpre a dificuldade
+	pow := NewProofOfWork(block)
+	if !pow.ValidateHash(block.Hash) {
+		return ruleError(ErrHashMismatch, "", "hash %x", block.Hash)
+	}
+	if !pow.Validate() {
+		return ruleError(ErrProofOfWork, "", "hash %x", block.Hash)
+	}
+
+	// 4. tempo: não volta antes do anterior nem vem do futuro
+	// (o genesis é criado localmente em cada nó, então o timestamp dele não conta)
+	limit := now.Add(MaxFutureDrift).Unix()
+	if !isGenesis(lastBlock) && block.Timestamp < lastBlock.Timestamp {
+		return ruleError(ErrTimestampPast, "", "got %d, previous %d", block.Timestamp, lastBlock.Timestamp)
+	}
+	if block.Timestamp > limit {
+		return ruleError(ErrTimestampFuture, "", "got %d, limit %d", block.Timestamp, limit)
+	}
+
+	// 5. exatamente uma coinbase, na primeira posição, com a recompensa certa
+	if err := validateCoinbase(block, height, nodeKeys); err != nil {
+		return err
+	}
+
+	// 6. cada transação
+	seen := make(map[string]bool, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		if seen[tx.ID] {
+			return ruleError(ErrDuplicateTx, tx.ID, "")
+		}
+		seen[tx.ID] = true
+
+		if err := validateTx(tx, limit); err != nil {
+			return err
+		}
+		if inChain(tx.ID) {
+			return ruleError(ErrTxReplay, tx.ID, "")
+		}
+		if tx.Type == models.TxBattleResult {
+			if err := validateBattleResult(tx, nodeKeys); err != nil {
+				return err
+			}
+		}
+		if err := state.Apply(tx); err != nil {
+			return ruleError(ErrTxState, tx.ID, "%v", err)
+		}
+
+		// request do jogador: assinado dentro da janela do bloco e usado uma vez só
+		if tx.Type == models.TxCoinbase {
+			continue
+		}
+		if err := checkTxWindow(tx, block.Timestamp); err != nil {
+			return err
+		}
+		key := requestKey(tx)
+		if seen[key] || inChain(key) {
+			return ruleError(ErrRequestReplay, tx.ID, "")
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// regras de uma tx isolada (formato, tamanho, tempo e assinatura)
+func validateTx(tx *models.Transaction, timeLimit int64) error {
+	if tx.ID == "" {
+		return ruleError(ErrTxFormat, "", "missing id")
+	}
+	if err := validateFormat(*tx); err != nil {
+		return ruleError(ErrTxFormat, tx.ID, "%v", err)
+	}
+
+	size := 0
+	for _, d := range tx.Data {
+		size += len(d)
+	}
+	for _, d := range tx.UserData {
+		size += len(d)
+	}
+	if size > MaxTxDataBytes {
+		return ruleError(ErrTxTooLarge, tx.ID, "%d bytes, max %d", size, MaxTxDataBytes)
+	}
+
+	if tx.Timestamp > timeLimit {
+		return ruleError(ErrTimestampFuture, tx.ID, "got %d, limit %d", tx.Timestamp, timeLimit)
+	}
+
+	if len(tx.PublicKey) == 0 || len(tx.Signature) == 0 || !VerifySignature(tx.PublicKey, tx.UserData, tx.Signature) {
+		return ruleError(ErrTxSignature, tx.ID, "")
+	}
+	return nil
+}
+
+func isGenesis(b *Block) bool {
+	return len(b.PreviousHash) == 0
+}
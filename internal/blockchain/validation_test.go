@@ -0,0 +1,456 @@
+package blockchain
+
+import (
+	"PlanoZ/internal/models"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blocos maliciosos contra o validador de consenso
+// a dificuldade cai pra 8 bits pra minerar rápido nos testes
+
+func TestMain(m *testing.M) {
+	targetBits = 8
+	os.Exit(m.Run())
+}
+
+var testKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+var testMiner = &minerIdentity{id: "server1", key: testKey}
+
+// diretório de chaves dos testes: só o testMiner está registrado
+func testNodeKeys(nodeID string) ([]byte, bool) {
+	if nodeID != testMiner.id {
+		return nil, false
+	}
+	return elliptic.Marshal(elliptic.P256(), testKey.PublicKey.X, testKey.PublicKey.Y), true
+}
+
+// cadeia nova com o diretório de chaves dos testes
+func newTestChain() *Blockchain {
+	b := New()
+	b.SetNodeKeys(testNodeKeys)
+	return b
+}
+
+// coinbase com recompensa e altura escolhidas (assinada de verdade)
+func coinbaseTx(t *testing.T, prev *Block, height, reward int) *models.Transaction {
+	t.Helper()
+
+	data := []string{testMiner.id, fmt.Sprintf("%d", reward), fmt.Sprintf("%d", height)}
+	userData := append(append([]string{}, data...), hex.EncodeToString(prev.Hash))
+	sig, err := signData(testKey, userData)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return &models.Transaction{
+		ID:        fmt.Sprintf("coinbase-%d-%x", height, prev.Hash),
+		Type:      models.TxCoinbase,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+		UserData:  userData,
+		PublicKey: elliptic.Marshal(elliptic.P256(), testKey.PublicKey.X, testKey.PublicKey.Y),
+		Signature: sig,
+	}
+}
+
+// tx de compra assinada do jeito que o servidor monta (UserData assinado pelo cliente)
+func signedTx(t *testing.T, id string) *models.Transaction {
+	t.Helper()
+	return signedRequestTx(t, id, "req-"+id, time.Now().Unix())
+}
+
+// idem, escolhendo o requestID e o horário da assinatura
+func signedRequestTx(t *testing.T, id, requestID string, signedAt int64) *models.Transaction {
+	t.Helper()
+
+	userData := []string{"payload", fmt.Sprintf("%d", signedAt), "player1", string(models.TxPurchase), requestID}
+	raw, _ := json.Marshal(userData)
+	hash := sha256.Sum256(raw)
+	r, s, err := ecdsa.Sign(rand.Reader, testKey, hash[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	// r e s com 32 bytes cada, o verificador divide a assinatura no meio
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	return &models.Transaction{
+		ID:        id,
+		Type:      models.TxPurchase,
+		Timestamp: time.Now().Unix(),
+		Data:      []string{"player1", `{"cards":[]}`, "BOOSTER_PACK"},
+		UserData:  userData,
+		PublicKey: elliptic.Marshal(elliptic.P256(), testKey.PublicKey.X, testKey.PublicKey.Y),
+		Signature: sig,
+	}
+}
+
+// roda o pow de verdade em cima dos campos do bloco
+func mine(b *Block) *Block {
+	ch := make(chan int)
+	b.Nonce, b.Hash = NewProofOfWork(b).Run(&ch)
+	return b
+}
+
+// bloco minerado com a coinbase certa na frente
+func newTestBlock(t *testing.T, prev *Block, height int, txs ...*models.Transaction) *Block {
+	t.Helper()
+	return mineTxs(prev, append([]*models.Transaction{coinbaseTx(t, prev, height, models.BlockReward)}, txs...)...)
+}
+
+// bloco minerado com exatamente as txs informadas
+func mineTxs(prev *Block, txs ...*models.Transaction) *Block {
+	return mine(&Block{
+		Timestamp:    time.Now().Unix(),
+		Transactions: txs,
+		PreviousHash: prev.Hash,
+	})
+}
+
+func expectRule(t *testing.T, err, rule error) {
+	t.Helper()
+	if !errors.Is(err, rule) {
+		t.Fatalf("expected %v, got %v", rule, err)
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %T", err)
+	}
+}
+
+func TestCheckNewBlockAcceptsValidBlock(t *testing.T) {
+	bc := newTestChain()
+	block := newTestBlock(t, bc.Ledger[0], 1, signedTx(t, "tx1"), signedTx(t, "tx2"))
+
+	if err := bc.CheckNewBlock(block); err != nil {
+		t.Fatalf("valid block rejected: %v", err)
+	}
+}
+
+func TestCheckNewBlockRejectsMaliciousBlocks(t *testing.T) {
+	bc := newTestChain()
+	genesis := bc.Ledger[0]
+
+	cases := []struct {
+		name  string
+		block func() *Block
+		rule  error
+	}{
+		{"missing hash", func() *Block {
+			return &Block{Transactions: []*models.Transaction{signedTx(t, "a")}, PreviousHash: genesis.Hash}
+		}, ErrMalformedBlock},
+		{"nil transaction", func() *Block {
+			return newTestBlock(t, genesis, 1, signedTx(t, "a"), nil)
+		}, ErrMalformedBlock},
+		{"empty block", func() *Block {
+			return mineTxs(genesis)
+		}, ErrEmptyBlock},
+		{"too many transactions", func() *Block {
+			// a coinbase ocupa um lugar, então MaxBlockTxs txs normais já estoura
+			txs := make([]*models.Transaction, MaxBlockTxs)
+			for i := range txs {
+				txs[i] = signedTx(t, fmt.Sprintf("tx%d", i))
+			}
+			return newTestBlock(t, genesis, 1, txs...)
+		}, ErrTooManyTxs},
+		{"wrong previous hash", func() *Block {
+			return newTestBlock(t, &Block{Hash: []byte("outra cadeia")}, 1, signedTx(t, "a"))
+		}, ErrPrevHash},
+		{"forged hash", func() *Block {
+			b := newTestBlock(t, genesis, 1, signedTx(t, "a"))
+			b.Hash = append([]byte{0, 0, 0, 0}, b.Hash[4:]...)
+			return b
+		}, ErrHashMismatch},
+		{"transaction tampered after mining", func() *Block {
+			b := newTestBlock(t, genesis, 1, signedTx(t, "a"))
+			b.Transactions[1].Data[0] = "attacker"
+			return b
+		}, ErrHashMismatch},
+		{"nonce tampered after mining", func() *Block {
+			b := newTestBlock(t, genesis, 1, signedTx(t, "a"))
+			b.Nonce++
+			return b
+		}, ErrHashMismatch},
+		{"hash matches contents but misses target", func() *Block {
+			b := &Block{Timestamp: time.Now().Unix(), Transactions: []*models.Transaction{coinbaseTx(t, genesis, 1, models.BlockReward)}, PreviousHash: genesis.Hash}
+			pow := NewProofOfWork(b)
+			for b.Nonce = 0; ; b.Nonce++ {
+				h := sha256.Sum256(pow.prepareData(b.Nonce))
+				b.Hash = h[:]
+				if !pow.Validate() {
+					return b
+				}
+			}
+		}, ErrProofOfWork},
+		{"timestamp in the future", func() *Block {
+			return mine(&Block{
+				Timestamp:    time.Now().Add(MaxFutureDrift + time.Hour).Unix(),
+				Transactions: []*models.Transaction{coinbaseTx(t, genesis, 1, models.BlockReward)},
+				PreviousHash: genesis.Hash,
+			})
+		}, ErrTimestampFuture},
+		{"transaction timestamp in the future", func() *Block {
+			tx := signedTx(t, "a")
+			tx.Timestamp = time.Now().Add(MaxFutureDrift + time.Hour).Unix()
+			return newTestBlock(t, genesis, 1, tx)
+		}, ErrTimestampFuture},
+		{"duplicate transaction ids", func() *Block {
+			return newTestBlock(t, genesis, 1, signedTx(t, "dup"), signedTx(t, "dup"))
+		}, ErrDuplicateTx},
+		{"missing transaction data", func() *Block {
+			tx := signedTx(t, "a")
+			tx.Data = tx.Data[:1]
+			return newTestBlock(t, genesis, 1, tx)
+		}, ErrTxFormat},
+		{"unknown transaction type", func() *Block {
+			tx := signedTx(t, "a")
+			tx.Type = "GENESIS"
+			return newTestBlock(t, genesis, 1, tx)
+		}, ErrTxFormat},
+		{"oversized transaction", func() *Block {
+			tx := signedTx(t, "a")
+			tx.Data[1] = strings.Repeat("x", MaxTxDataBytes)
+			return newTestBlock(t, genesis, 1, tx)
+		}, ErrTxTooLarge},
+		{"missing coinbase", func() *Block {
+			return mineTxs(genesis, signedTx(t, "a"))
+		}, ErrCoinbaseMissing},
+		{"coinbase not first", func() *Block {
+			return mineTxs(genesis, signedTx(t, "a"), coinbaseTx(t, genesis, 1, models.BlockReward))
+		}, ErrCoinbaseMissing},
+		{"two coinbases", func() *Block {
+			extra := coinbaseTx(t, genesis, 1, models.BlockReward)
+			extra.ID = "coinbase-extra"
+			return newTestBlock(t, genesis, 1, extra)
+		}, ErrCoinbaseExtra},
+		{"inflated reward", func() *Block {
+			return mineTxs(genesis, coinbaseTx(t, genesis, 1, 1000))
+		}, ErrCoinbaseAmount},
+		{"wrong coinbase height", func() *Block {
+			return mineTxs(genesis, coinbaseTx(t, genesis, 7, models.BlockReward))
+		}, ErrCoinbaseHeight},
+		{"coinbase reused from another block", func() *Block {
+			other := &Block{Hash: []byte("outro bloco")}
+			return mineTxs(genesis, coinbaseTx(t, other, 1, models.BlockReward))
+		}, ErrCoinbaseInvalid},
+		{"coinbase miner swapped after signing", func() *Block {
+			cb := coinbaseTx(t, genesis, 1, models.BlockReward)
+			cb.Data[0] = "attacker"
+			return mineTxs(genesis, cb)
+		}, ErrCoinbaseInvalid},
+		{"coinbase signed by another key", func() *Block {
+			other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			cb, err := newCoinbase(&minerIdentity{id: testMiner.id, key: other}, 1, genesis.Hash)
+			if err != nil {
+				t.Fatalf("coinbase: %v", err)
+			}
+			return mineTxs(genesis, cb)
+		}, ErrUnknownSigner},
+		{"coinbase from unregistered miner", func() *Block {
+			cb, err := newCoinbase(&minerIdentity{id: "server9", key: testKey}, 1, genesis.Hash)
+			if err != nil {
+				t.Fatalf("coinbase: %v", err)
+			}
+			return mineTxs(genesis, cb)
+		}, ErrUnknownSigner},
+		{"coinbase with forged signature", func() *Block {
+			cb := coinbaseTx(t, genesis, 1, models.BlockReward)
+			cb.Signature[0] ^= 0xff
+			return mineTxs(genesis, cb)
+		}, ErrTxSignature},
+		{"forged signature", func() *Block {
+			tx := signedTx(t, "a")
+			tx.UserData[2] = "attacker"
+			return newTestBlock(t, genesis, 1, tx)
+		}, ErrTxSignature},
+		{"missing signature", func() *Block {
+			tx := signedTx(t, "a")
+			tx.PublicKey, tx.Signature = nil, nil
+			return newTestBlock(t, genesis, 1, tx)
+		}, ErrTxSignature},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expectRule(t, bc.CheckNewBlock(tc.block()), tc.rule)
+		})
+	}
+}
+
+func TestCheckNewBlockRejectsTimestampBeforePrevious(t *testing.T) {
+	bc := newTestChain()
+	first := newTestBlock(t, bc.Ledger[0], 1, signedTx(t, "tx1"))
+	bc.AddBlock(first)
+
+	second := mine(&Block{
+		Timestamp:    first.Timestamp - 60,
+		Transactions: []*models.Transaction{coinbaseTx(t, first, 2, models.BlockReward)},
+		PreviousHash: first.Hash,
+	})
+	expectRule(t, bc.CheckNewBlock(second), ErrTimestampPast)
+}
+
+func TestCheckNewBlockRejectsReplayedTransaction(t *testing.T) {
+	bc := newTestChain()
+	tx := signedTx(t, "tx1")
+	first := newTestBlock(t, bc.Ledger[0], 1, tx)
+	bc.AddBlock(first)
+
+	replay := newTestBlock(t, first, 2, tx)
+	expectRule(t, bc.CheckNewBlock(replay), ErrTxReplay)
+}
+
+func TestBattleResultOnlyFromRegisteredHost(t *testing.T) {
+	bc := newTestChain()
+	genesis := bc.Ledger[0]
+
+	result := func(t *testing.T, host string, key *ecdsa.PrivateKey) *models.Transaction {
+		t.Helper()
+		tx, err := NewBattleResult(host, key, models.BattleResultPayload{
+			BattleID: "b1",
+			Winner:   "player1",
+			Cards:    []string{"c1", "c2"},
+			Players:  []string{"player1", "player2"},
+		})
+		if err != nil {
+			t.Fatalf("battle result: %v", err)
+		}
+		return &tx
+	}
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	t.Run("signed by the host", func(t *testing.T) {
+		if err := bc.CheckNewBlock(newTestBlock(t, genesis, 1, result(t, testMiner.id, testKey))); err != nil {
+			t.Fatalf("valid battle result rejected: %v", err)
+		}
+	})
+	t.Run("signed by a player claiming to be the host", func(t *testing.T) {
+		expectRule(t, bc.CheckNewBlock(newTestBlock(t, genesis, 1, result(t, testMiner.id, other))), ErrUnknownSigner)
+	})
+	t.Run("unregistered host", func(t *testing.T) {
+		expectRule(t, bc.CheckNewBlock(newTestBlock(t, genesis, 1, result(t, "player1", other))), ErrUnknownSigner)
+	})
+	t.Run("winner swapped after signing", func(t *testing.T) {
+		tx := result(t, testMiner.id, testKey)
+		tx.Data[2] = "player2"
+		expectRule(t, bc.CheckNewBlock(newTestBlock(t, genesis, 1, tx)), ErrTxFormat)
+	})
+	t.Run("mempool refuses a forged result", func(t *testing.T) {
+		if err := bc.AddTransaction(*result(t, testMiner.id, other)); !errors.Is(err, ErrUnknownSigner) {
+			t.Fatalf("expected %v, got %v", ErrUnknownSigner, err)
+		}
+	})
+}
+
+func TestReplaceChainRejectsMaliciousChain(t *testing.T) {
+	bc := newTestChain()
+	genesis := bc.Ledger[0]
+
+	t.Run("replay across blocks", func(t *testing.T) {
+		tx := signedTx(t, "tx1")
+		b1 := newTestBlock(t, genesis, 1, tx)
+		b2 := newTestBlock(t, b1, 2, tx)
+
+		err := bc.ReplaceChain([]*Block{genesis, b1, b2})
+		expectRule(t, err, ErrTxReplay)
+	})
+
+	t.Run("tampered block in the middle", func(t *testing.T) {
+		b1 := newTestBlock(t, genesis, 1, signedTx(t, "tx1"))
+		b2 := newTestBlock(t, b1, 2, signedTx(t, "tx2"))
+		b1.Transactions[1].Data[0] = "attacker"
+
+		err := bc.ReplaceChain([]*Block{genesis, b1, b2})
+		expectRule(t, err, ErrHashMismatch)
+	})
+
+	if bc.CurrentHeight() != 1 {
+		t.Fatalf("chain replaced by malicious chain, height %d", bc.CurrentHeight())
+	}
+
+	t.Run("valid chain is accepted", func(t *testing.T) {
+		b1 := newTestBlock(t, genesis, 1, signedTx(t, "tx1"))
+		b2 := newTestBlock(t, b1, 2, signedTx(t, "tx2"))
+
+		if err := bc.ReplaceChain([]*Block{genesis, b1, b2}); err != nil {
+			t.Fatalf("valid chain rejected: %v", err)
+		}
+		if bc.CurrentHeight() != 3 {
+			t.Fatalf("expected height 3, got %d", bc.CurrentHeight())
+		}
+	})
+}
+
+func TestMineBlockAddsCoinbaseAndCreditsMiner(t *testing.T) {
+	bc := newTestChain()
+	bc.SetMiner(testMiner.id, testKey)
+	bc.MPool = append(bc.MPool, *signedTx(t, "tx1"))
+
+	block, err := bc.MineBlock()
+	if err != nil {
+		t.Fatalf("mine: %v", err)
+	}
+	if err := bc.CheckNewBlock(block); err != nil {
+		t.Fatalf("mined block rejected: %v", err)
+	}
+	bc.AddBlock(block)
+
+	if got := bc.State().Balances[MinerAccount(testMiner.id)]; got != models.BlockReward {
+		t.Fatalf("expected miner balance %d, got %d", models.BlockReward, got)
+	}
+
+	stats := bc.MinerStats()
+	if len(stats) != 1 || stats[0].MinerID != testMiner.id || stats[0].Blocks != 1 || stats[0].Rewards != models.BlockReward {
+		t.Fatalf("unexpected miner stats: %+v", stats)
+	}
+}
+
+func TestAddTransactionRejectsCoinbase(t *testing.T) {
+	bc := newTestChain()
+	if err := bc.AddTransaction(*coinbaseTx(t, bc.Ledger[0], 1, models.BlockReward)); err == nil {
+		t.Fatal("coinbase accepted into mempool")
+	}
+}
+
+func TestCheckNewBlockRejectsTxInvalidForState(t *testing.T) {
+	bc := newTestChain()
+
+	// compra no mercado de anúncio que não existe (sem saldo e sem carta)
+	req := signRequest(t, testKey, "player1", models.TxMarketBuy, models.MarketListingPayload{ListingID: "nope"})
+	block := newTestBlock(t, bc.Ledger[0], 1, signedTx(t, "tx1"), requestTx("mb1", req, "player1", "nope"))
+	expectRule(t, bc.CheckNewBlock(block), ErrTxState)
+}
+
+func TestMineBlockSkipsTxInvalidForState(t *testing.T) {
+	bc := newTestChain()
+	bc.SetMiner(testMiner.id, testKey)
+	req := signRequest(t, testKey, "player1", models.TxMarketBuy, models.MarketListingPayload{ListingID: "nope"})
+	bc.MPool = append(bc.MPool, *requestTx("mb1", req, "player1", "nope"), *signedTx(t, "tx1"))
+
+	block, err := bc.MineBlock()
+	if err != nil {
+		t.Fatalf("mine: %v", err)
+	}
+	if len(block.Transactions) != 2 || block.Transactions[1].ID != "tx1" {
+		t.Fatalf("expected coinbase + tx1, got %d txs", len(block.Transactions))
+	}
+	if err := bc.CheckNewBlock(block); err != nil {
+		t.Fatalf("mined block rejected: %v", err)
+	}
+	if status := bc.TxStatus("mb1"); status.Status != models.TxStatusDropped {
+		t.Fatalf("expected mb1 dropped, got %+v", status)
+	}
+}
@@ -8,21 +8,48 @@ const (
 	RarityRare     = "rara"
 )
 
+// categorias dos tanques (pedra-papel-tesoura na batalha)
+const (
+	CategoryLight  = "light"
+	CategoryMedium = "medium"
+	CategoryHeavy  = "heavy"
+)
+
+// habilidades especiais (vazio = sem habilidade)
+const (
+	AbilityNone          = ""
+	AbilityFlank         = "flanquear"         // ignora a blindagem do alvo
+	AbilityPrecision     = "tiro_preciso"      // ignora metade da blindagem
+	AbilityHowitzer      = "obus"              // dano extra contra pesados
+	AbilityReactiveArmor = "blindagem_reativa" // reduz o dano recebido
+	AbilityQuickReload   = "recarga_rapida"    // sempre atira primeiro
+)
+
 type Tanque struct {
-	ID        string `json:"id"`
-	Modelo    string `json:"modelo"`
-	Raridade  string `json:"raridade"`
-	Vida      int    `json:"vida"`
-	Ataque    int    `json:"ataque"`
-	OwnerID   string `json:"owner_id"`
-	Timestamp int64  `json:"timestamp"`
+	ID         string `json:"id"`
+	Modelo     string `json:"modelo"`
+	Raridade   string `json:"raridade"`
+	Categoria  string `json:"categoria"`
+	Vida       int    `json:"vida"`
+	Ataque     int    `json:"ataque"`
+	Blindagem  int    `json:"blindagem"`
+	Velocidade int    `json:"velocidade"`
+	Habilidade string `json:"habilidade,omitempty"`
+	Edicao     string `json:"edicao"`
+	OwnerID    string `json:"owner_id"`
+	Timestamp  int64  `json:"timestamp"`
 }
 
 type CardData struct {
-	Modelo   string `json:"modelo"`
-	Raridade string `json:"raridade"`
-	Vida     int    `json:"vida"`
-	Ataque   int    `json:"ataque"`
+	Modelo     string `json:"modelo"`
+	Raridade   string `json:"raridade"`
+	Categoria  string `json:"categoria"`
+	Vida       int    `json:"vida"`
+	Ataque     int    `json:"ataque"`
+	Blindagem  int    `json:"blindagem"`
+	Velocidade int    `json:"velocidade"`
+	Habilidade string `json:"habilidade,omitempty"`
+	Edicao     string `json:"edicao"`
 }
 
 type Booster struct {
@@ -37,19 +64,20 @@ type PlayerInfo struct {
 	ServerID     string `json:"server_id"`
 	ServerHost   string `json:"server_host"`
 	ReplyChannel string `json:"reply_channel"`
+	LastSeen     int64  `json:"last_seen"`
 }
 
 // estado da partida
 type Batalha struct {
-	ID           string      `json:"id"`
-	Jogador1     string      `json:"jogador1"`
-	Jogador2     string      `json:"jogador2"`
-	ServidorJ1   string      `json:"servidor_j1"`
-	ServidorJ2   string      `json:"servidor_j2"`
-	CanalJ1      chan Tanque `json:"-"`
-	CanalJ2      chan Tanque `json:"-"`
-	CanalEncerra chan bool   `json:"-"`
-	Estado       string      `json:"estado"`
+	ID         string   `json:"id"`
+	Jogador1   string   `json:"jogador1"`
+	Jogador2   string   `json:"jogador2"`
+	ServidorJ1 string   `json:"servidor_j1"`
+	ServidorJ2 string   `json:"servidor_j2"`
+	Estado     string   `json:"estado"`
+	Vencedor   string   `json:"vencedor,omitempty"` // vazio = empate ou cancelada
+	Cartas     []string `json:"cartas,omitempty"`   // cartas usadas pelos dois, turno a turno
+	TxID       string   `json:"tx_id,omitempty"`    // BR assinado pelo host
 }
 
 // estado da negociacao de troca
@@ -84,6 +112,61 @@ type HealthCheckResponse struct {
 	Status   string `json:"status"`
 	ServerID string `json:"server_id"`
 	IsLeader bool   `json:"is_leader"`
+	Leader   string `json:"leader"`
+	Term     int64  `json:"term"`
+	Height   int    `json:"height"`
+}
+
+// estado de prontidão do servidor (GET /ready)
+type ReadinessResponse struct {
+	Ready      bool   `json:"ready"`
+	Mode       string `json:"mode"`
+	Started    bool   `json:"started"` // health checks e eleição já rodando
+	RedisOK    bool   `json:"redis_ok"`
+	Peers      int    `json:"peers"`
+	MinPeers   int    `json:"min_peers"`
+	Height     int    `json:"height"`
+	PeerHeight int    `json:"peer_height"` // maior altura vista nos peers
+	Synced     bool   `json:"synced"`
+	CheckedAt  int64  `json:"checked_at"`
+}
+
+// membro do cluster, visto por algum servidor
+type ClusterMember struct {
+	ID       string `json:"id"`
+	Host     string `json:"host"`      // api interna do cluster
+	APIHost  string `json:"api_host"`  // api publica (clientes)
+	LastSeen int64  `json:"last_seen"` // unix, ultima vez que respondeu health
+}
+
+type ClusterJoinRequest struct {
+	ID      string `json:"id"`
+	Host    string `json:"host"`
+	APIHost string `json:"api_host"`
+}
+
+type ClusterLeaveRequest struct {
+	ID string `json:"id"`
+}
+
+type ClusterMembersResponse struct {
+	Members []ClusterMember `json:"members"`
+}
+
+// anúncio de inventário (gossip de blocos e txs)
+// quem recebe responde com os ids que ainda não tem (getdata) e só esses são enviados
+const (
+	InvBlock = "block"
+	InvTx    = "tx"
+)
+
+type InvRequest struct {
+	Kind string   `json:"kind"` // InvBlock | InvTx
+	IDs  []string `json:"ids"`  // hash do bloco em hex ou id da tx
+}
+
+type InvResponse struct {
+	Want []string `json:"want"`
 }
 
 type LeaderConnectRequest struct {
@@ -91,8 +174,34 @@ type LeaderConnectRequest struct {
 	ServerID     string `json:"server_id"`
 	ServerHost   string `json:"server_host"`
 	ReplyChannel string `json:"reply_channel"`
+	Term         int64  `json:"term,omitempty"` // termo do líder que quem mandou conhece
 }
 
+// eventos incrementais do registro de players (líder -> seguidores)
+const (
+	PlayerJoined = "joined"
+	PlayerMoved  = "moved"
+	PlayerLeft   = "left"
+)
+
+type PlayerEvent struct {
+	Seq      uint64     `json:"seq"`  // monotônico dentro do termo
+	Term     int64      `json:"term"` // termo do líder que gerou
+	LeaderID string     `json:"leader_id"`
+	Kind     string     `json:"kind"`
+	PlayerID string     `json:"player_id"`
+	Info     PlayerInfo `json:"info"`
+}
+
+// resposta do resync (GET /players/events?since=N)
+type PlayerEventsResponse struct {
+	Term   int64         `json:"term"`
+	Seq    uint64        `json:"seq"`   // ultimo seq do líder
+	Reset  bool          `json:"reset"` // o since é mais velho que o log: descarta o cache
+	Events []PlayerEvent `json:"events"`
+}
+
+// heartbeat e desconexão do jogador
 // requests de batalha
 type BattleInitiateRequest struct {
 	IdBatalha      string `json:"id_batalha"`
@@ -106,25 +215,6 @@ type RespostaInicioBatalha struct {
 	IdBatalha string `json:"id_batalha"`
 }
 
-type BattleRequestMoveRequest struct {
-	IdBatalha string `json:"id_batalha"`
-}
-
-type BattleSubmitMoveRequest struct {
-	IdBatalha string `json:"id_batalha"`
-	Carta     Tanque `json:"carta"`
-}
-
-type BattleTurnResultRequest struct {
-	IdBatalha string `json:"id_batalha"`
-	Resultado string `json:"resultado"`
-}
-
-type BattleEndRequest struct {
-	IdBatalha string `json:"id_batalha"`
-	Resultado string `json:"resultado"`
-}
-
 // requests de troca
 type TradeInitiateRequest struct {
 	IdTroca        string `json:"id_troca"`
@@ -154,10 +244,13 @@ type TradeResultRequest struct {
 
 // requests pro redis 
 type ReqConectar struct {
-	PlayerID string `json:"player_id"`
+	PlayerID     string `json:"player_id"`
+	ReplyChannel string `json:"reply_channel"`
 }
 type ReqComprarCarta struct {
-	PlayerID string `json:"player_id"`
+	PlayerID     string             `json:"player_id"`
+	ReplyChannel string             `json:"reply_channel"`
+	Request      TransactionRequest `json:"request"` // compra assinada
 }
 
 // parte da blockchain e transações
@@ -168,8 +261,27 @@ const (
 	TxPurchase     TransactionType = "PC"
 	TxTrade        TransactionType = "TD"
 	TxBattleResult TransactionType = "BR"
+	TxCraft        TransactionType = "CF"
+	TxMarketList   TransactionType = "ML"
+	TxMarketCancel TransactionType = "MC"
+	TxMarketBuy    TransactionType = "MB"
+	TxCoinbase     TransactionType = "CB" // recompensa do minerador, sempre a 1a tx do bloco
+
+	// pedido assinado pra começar batalha (não vira tx, só prova que o jogador quis jogar)
+	ReqBattleStart TransactionType = "BS"
+
+	// sessão do jogador no registro (também só request assinado, tipos separados
+	// pra um heartbeat capturado não servir de disconnect)
+	ReqHeartbeat  TransactionType = "HB"
+	ReqDisconnect TransactionType = "DC"
 )
 
+// quantos tokens o vencedor ganha por batalha registrada
+const BattleReward = 10
+
+// quantos tokens o servidor ganha por bloco minerado
+const BlockReward = 5
+
 type Transaction struct {
 	ID        string          `json:"id"`
 	Type      TransactionType `json:"type"`
@@ -185,6 +297,7 @@ type TransactionRequest struct {
 	UserID    string          `json:"user_id"`
 	Timestamp int64           `json:"timestamp"`
 	Payload   string          `json:"payload"`
+	RequestID string          `json:"request_id"` // gerado pelo cliente, assinado (anti-replay)
 	PublicKey []byte          `json:"public_key"`
 	Signature []byte          `json:"signature"`
 }
@@ -198,11 +311,175 @@ type TradePayload struct {
 	UserTarget string `json:"user_target"`
 	CardMy     string `json:"card_my"`
 	CardTarget string `json:"card_target"`
+	// aceite do outro jogador: request TD dele assinando os mesmos termos espelhados
+	// (só quem registra manda; no aceite fica vazio)
+	Accept *TransactionRequest `json:"accept,omitempty"`
 }
 
+// estatísticas de um minerador, montadas a partir das coinbases do ledger
+type MinerStats struct {
+	MinerID     string `json:"miner_id"`
+	PublicKey   string `json:"public_key"` // chave do nó que assinou a coinbase (hex)
+	Blocks      int    `json:"blocks"`
+	Rewards     int    `json:"rewards"`
+	LastHeight  int    `json:"last_height"`
+	LastMinedAt int64  `json:"last_mined_at"`
+}
+
+// explorer da blockchain
+
+// resumo de um bloco (sem as txs)
+type ExplorerBlock struct {
+	Height       int    `json:"height"`
+	Hash         string `json:"hash"`
+	PreviousHash string `json:"previous_hash"`
+	Timestamp    int64  `json:"timestamp"`
+	Nonce        int    `json:"nonce"`
+	TxCount      int    `json:"tx_count"`
+	Miner        string `json:"miner,omitempty"`
+}
+
+// bloco completo, com as txs
+type ExplorerBlockDetail struct {
+	ExplorerBlock
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// tx com a posição dela na cadeia
+type ExplorerTx struct {
+	Height    int          `json:"height"`
+	BlockHash string       `json:"block_hash"`
+	Index     int          `json:"index"`
+	Tx        *Transaction `json:"tx"`
+}
+
+// página de resultados (Items é []ExplorerBlock ou []ExplorerTx)
+type ExplorerPage struct {
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+	Total int         `json:"total"`
+	Items interface{} `json:"items"`
+}
+
+type ExplorerStats struct {
+	Height           int                     `json:"height"`
+	LastBlockHash    string                  `json:"last_block_hash"`
+	LastBlockAt      int64                   `json:"last_block_at"`
+	AvgBlockInterval float64                 `json:"avg_block_interval_s"`
+	TotalTxs         int                     `json:"total_txs"`
+	TxsByType        map[TransactionType]int `json:"txs_by_type"`
+	Players          int                     `json:"players"`
+	Cards            int                     `json:"cards"`
+	MempoolSize      int                     `json:"mempool_size"`
+}
+
+// eventos em tempo real (GET /events, server-sent events)
+
+const (
+	EventTopicBlocks = "blocks" // block_added, chain_replaced
+	EventTopicTxs    = "txs"    // tx_accepted, tx_confirmed
+	EventTopicLeader = "leader" // leader_changed
+
+	EventBlockAdded    = "block_added"
+	EventChainReplaced = "chain_replaced"
+	EventTxAccepted    = "tx_accepted"
+	EventTxConfirmed   = "tx_confirmed"
+	EventLeaderChanged = "leader_changed"
+)
+
+type StreamEvent struct {
+	ID        uint64      `json:"id"` // sequencial por servidor
+	Topic     string      `json:"topic"`
+	Type      string      `json:"type"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+type TxEventData struct {
+	TxID      string          `json:"tx_id"`
+	Type      TransactionType `json:"type"`
+	Height    int             `json:"height,omitempty"` // só no tx_confirmed
+	BlockHash string          `json:"block_hash,omitempty"`
+}
+
+type LeaderEventData struct {
+	Leader string `json:"leader"` // vazio = sem líder
+	Term   int64  `json:"term"`
+}
+
+// payload do pedido de batalha (ReqBattleStart)
+// quem desafia manda sem Challenge; o servidor repassa pro oponente, que aceita
+// mandando o próprio pedido com o desafio assinado dentro
+type BattleStartPayload struct {
+	BattleID  string              `json:"battle_id"`
+	Opponent  string              `json:"opponent"`
+	Challenge *TransactionRequest `json:"challenge,omitempty"`
+}
+
+// resultado que o host assina no BR (e o cliente manda pra consultar o registro)
 type BattleResultPayload struct {
-	BattleID string `json:"battle_id"`
-	Winner   string `json:"winner"`
+	BattleID string   `json:"battle_id"`
+	Winner   string   `json:"winner"`            // vazio = empate
+	Cards    []string `json:"cards,omitempty"`   // cartas usadas, alternando jogador1 e jogador2 por turno
+	Players  []string `json:"players,omitempty"` // [jogador1, jogador2]
+}
+
+// histórico de uma carta (proveniência), montado pelo listener a partir do ledger
+
+const (
+	CardEventMint         = "mint"          // saiu num booster
+	CardEventTrade        = "trade"         // trocada entre jogadores
+	CardEventBattle       = "battle"        // usada numa batalha
+	CardEventCraft        = "craft"         // fabricada queimando duplicatas
+	CardEventBurn         = "burn"          // queimada num craft
+	CardEventMarketList   = "market_list"   // anunciada no mercado
+	CardEventMarketCancel = "market_cancel" // anúncio cancelado
+	CardEventMarketSale   = "market_sale"   // vendida no mercado
+)
+
+type CardHistoryEvent struct {
+	Event        string `json:"event"`
+	TxID         string `json:"tx_id"`
+	Height       int    `json:"height"`
+	BlockHash    string `json:"block_hash"`
+	Timestamp    int64  `json:"timestamp"`
+	From         string `json:"from,omitempty"`         // dono antes
+	To           string `json:"to,omitempty"`           // dono depois
+	Counterparty string `json:"counterparty,omitempty"` // o outro lado da troca/venda
+	BattleID     string `json:"battle_id,omitempty"`
+	Winner       string `json:"winner,omitempty"`
+	Price        string `json:"price,omitempty"`
+}
+
+type CardHistoryResponse struct {
+	CardID string             `json:"card_id"`
+	Owner  string             `json:"owner"`
+	Events []CardHistoryEvent `json:"events"`
+}
+
+// cartas que o jogador quer queimar (duplicatas do mesmo modelo)
+type CraftPayload struct {
+	CardIDs []string `json:"card_ids"`
+}
+
+// anunciar carta no mercado
+type MarketListPayload struct {
+	CardID string `json:"card_id"`
+	Price  int    `json:"price"`
+}
+
+// cancelar ou comprar um anúncio
+type MarketListingPayload struct {
+	ListingID string `json:"listing_id"`
+}
+
+// anúncio ativo no mercado (o id é o da tx que criou)
+type MarketListing struct {
+	ID        string `json:"id"`
+	SellerID  string `json:"seller_id"`
+	Card      Tanque `json:"card"`
+	Price     int    `json:"price"`
+	Timestamp int64  `json:"timestamp"`
 }
 
 type AsyncResponse struct {
@@ -210,3 +487,24 @@ type AsyncResponse struct {
 	TxID    string `json:"tx_id,omitempty"`
 	Status  string `json:"status"`
 }
+
+// ciclo de vida de uma tx (GET /tx/:id/status)
+type TxStatus string
+
+const (
+	TxStatusPending  TxStatus = "pending"  // na mempool, esperando mineração
+	TxStatusMined    TxStatus = "mined"    // num bloco da cadeia
+	TxStatusRejected TxStatus = "rejected" // recusada na validação (ver reason)
+	TxStatusDropped  TxStatus = "dropped"  // saiu da mempool/cadeia sem ser minerada (ver reason)
+	TxStatusUnknown  TxStatus = "unknown"  // esse nó nunca viu
+)
+
+type TxStatusResponse struct {
+	TxID          string   `json:"tx_id"`
+	Status        TxStatus `json:"status"`
+	Reason        string   `json:"reason,omitempty"`
+	BlockHash     string   `json:"block_hash,omitempty"`
+	Height        int      `json:"height,omitempty"`
+	Confirmations int      `json:"confirmations,omitempty"` // 1 = está no último bloco
+	UpdatedAt     int64    `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// notificações que o servidor manda pro canal do jogador (stream player_reply_<id>)
+// servidor e cliente usam o mesmo envelope e o mesmo codec, então os dois lados
+// concordam sobre o formato de cada tipo
+
+// versão do formato do envelope; sobe quando algum payload muda de forma incompatível
+const NotificationVersion = 1
+
+// tipos de notificação
+const (
+	// blockchain
+	NotifCompraSucesso   = "Compra_Sucesso"
+	NotifTrocaConfirmada = "Troca_Confirmada"
+	NotifCraftSucesso    = "Craft_Sucesso"
+	NotifRankUpdate      = "Rank_Update"
+
+	// finalidade: a tx entrou num bloco mas ainda pode ser desfeita por reorg
+	// (as de cima só saem quando o bloco tem FINALITY_DEPTH confirmações)
+	NotifTxProvisoria = "Tx_Provisoria"
+	NotifTxRevertida  = "Tx_Revertida"
+
+	// mercado
+	NotifMercadoAnunciado = "Mercado_Anunciado"
+	NotifMercadoCancelado = "Mercado_Cancelado"
+	NotifMercadoComprado  = "Mercado_Comprado"
+	NotifMercadoVendido   = "Mercado_Vendido"
+
+	// fila de comandos
+	NotifConectado    = "Conectado"
+	NotifCompraAceita = "Compra_Aceita"
+	NotifCompraErro   = "Compra_Erro"
+
+	// batalha
+	NotifDesafioBatalha = "Desafio_Batalha"
+	NotifInicioBatalha  = "Inicio_Batalha"
+	NotifResultadoTurno = "Resultado_Turno"
+	NotifFimBatalha     = "Fim_Batalha"
+
+	// troca
+	NotifInicioTroca    = "Inicio_Troca"
+	NotifSuaVezTroca    = "Sua_Vez_Troca"
+	NotifResultadoTroca = "Resultado_Troca"
+)
+
+var (
+	ErrNotificationMalformed = errors.New("notificação malformada")
+	ErrNotificationVersion   = errors.New("versão de notificação não suportada")
+)
+
+// envelope que vai no campo "data" do stream
+type Notification struct {
+	Versao  int             `json:"versao"`
+	Tipo    string          `json:"tipo"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// payloads de cada tipo
+// Compra_Aceita usa AsyncResponse, Inicio_Batalha usa RespostaInicioBatalha
+// e Inicio_Troca usa RespostaInicioTroca
+
+type NotifCompraSucessoPayload struct {
+	Mensagem string  `json:"mensagem"`
+	Booster  Booster `json:"booster"`
+	TxID     string  `json:"tx_id"`
+}
+
+type NotifTxProvisoriaPayload struct {
+	Mensagem     string          `json:"mensagem"`
+	TxID         string          `json:"tx_id"`
+	Tipo         TransactionType `json:"tipo"`
+	Altura       int             `json:"altura"`
+	Confirmacoes int             `json:"confirmacoes"`
+	Necessarias  int             `json:"necessarias"` // confirmações pra virar final
+}
+
+type NotifTxRevertidaPayload struct {
+	Mensagem string          `json:"mensagem"`
+	TxID     string          `json:"tx_id"`
+	Tipo     TransactionType `json:"tipo"`
+}
+
+type NotifTrocaConfirmadaPayload struct {
+	Mensagem string `json:"mensagem"`
+	TxID     string `json:"tx_id"`
+}
+
+type NotifCraftSucessoPayload struct {
+	Mensagem  string   `json:"mensagem"`
+	Queimadas []string `json:"queimadas"`
+	Carta     Tanque   `json:"carta"`
+	TxID      string   `json:"tx_id"`
+}
+
+type NotifRankUpdatePayload struct {
+	Mensagem string `json:"mensagem"`
+	Tokens   int    `json:"tokens"`
+	TxID     string `json:"tx_id"`
+}
+
+// Mercado_Anunciado e Mercado_Cancelado
+type NotifMercadoAnuncioPayload struct {
+	Mensagem  string `json:"mensagem"`
+	ListingID string `json:"listing_id"`
+	CardID    string `json:"card_id,omitempty"`
+	TxID      string `json:"tx_id"`
+}
+
+type NotifMercadoCompradoPayload struct {
+	Mensagem string `json:"mensagem"`
+	Carta    Tanque `json:"carta"`
+	Preco    string `json:"preco"`
+	TxID     string `json:"tx_id"`
+}
+
+type NotifMercadoVendidoPayload struct {
+	Mensagem string `json:"mensagem"`
+	CardID   string `json:"card_id"`
+	Preco    string `json:"preco"`
+	TxID     string `json:"tx_id"`
+}
+
+type NotifConectadoPayload struct {
+	Mensagem string `json:"mensagem"`
+	ServerID string `json:"server_id"`
+}
+
+type NotifCompraErroPayload struct {
+	Mensagem string `json:"mensagem"`
+}
+
+// Sua_Vez e Sua_Vez_Troca
+type NotifSuaVezPayload struct {
+	Mensagem string `json:"mensagem"`
+	ID       string `json:"id"` // id da batalha ou da troca
+}
+
+// desafio assinado de outro jogador, pra aceitar é só mandar de volta dentro do pedido
+type NotifDesafioBatalhaPayload struct {
+	Mensagem   string             `json:"mensagem"`
+	IdBatalha  string             `json:"id_batalha"`
+	Desafiante string             `json:"desafiante"`
+	Desafio    TransactionRequest `json:"desafio"`
+}
+
+// Resultado_Turno e Fim_Batalha
+type NotifBatalhaPayload struct {
+	IdBatalha string `json:"id_batalha"`
+	Resultado string `json:"resultado"`
+	Vencedor  string `json:"vencedor,omitempty"` // só no fim da batalha
+}
+
+type NotifResultadoTrocaPayload struct {
+	IdTroca       string `json:"id_troca"`
+	CartaRecebida Tanque `json:"carta_recebida"`
+}
+
+// monta o envelope já serializado
+func EncodeNotification(tipo string, payload interface{}) ([]byte, error) {
+	if tipo == "" {
+		return nil, fmt.Errorf("%w: tipo vazio", ErrNotificationMalformed)
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Notification{Versao: NotificationVersion, Tipo: tipo, Payload: raw})
+}
+
+// lê o envelope sem entrar no payload; nunca entra em pânico com lixo
+// versão 0 = mensagem antiga, sem o campo, tratada como v1
+func DecodeNotification(data []byte) (Notification, error) {
+	var n Notification
+	if err := json.Unmarshal(data, &n); err != nil {
+		return n, fmt.Errorf("%w: %v", ErrNotificationMalformed, err)
+	}
+	if n.Tipo == "" {
+		return n, fmt.Errorf("%w: sem tipo", ErrNotificationMalformed)
+	}
+	if n.Versao > NotificationVersion {
+		return n, fmt.Errorf("%w: %d", ErrNotificationVersion, n.Versao)
+	}
+	return n, nil
+}
+
+// decodifica o payload no struct do tipo
+func (n Notification) Decode(v interface{}) error {
+	if len(n.Payload) == 0 {
+		return fmt.Errorf("%w: %s sem payload", ErrNotificationMalformed, n.Tipo)
+	}
+	if err := json.Unmarshal(n.Payload, v); err != nil {
+		return fmt.Errorf("%w: payload de %s: %v", ErrNotificationMalformed, n.Tipo, err)
+	}
+	return nil
+}
@@ -13,6 +13,12 @@ import (
 
 const CARDS_PER_BOOSTER = 3
 
+// quantas cópias iguais o jogador queima pra fabricar uma carta mais rara
+const CRAFT_COST = 3
+
+// ordem das raridades, da mais comum pra mais rara
+var rarityOrder = []string{models.RarityCommon, models.RarityUncommon, models.RarityRare}
+
 type CardDB struct {
 	// usado para guardar o que leu do json
 	definitions map[string]models.CardData
@@ -102,21 +108,67 @@ func (cd *CardDB) CreateCardPool(glossary map[string]models.CardData, copies map
 		}
 
 		for i := 0; i < quantity; i++ {
-			instance := models.Tanque{
-				ID:        uuid.New().String(), // gera id unico, importante pro ledger
-				Modelo:    data.Modelo,
-				Raridade:  data.Raridade,
-				Vida:      data.Vida,
-				Ataque:    data.Ataque,
-				Timestamp: time.Now().Unix(),
-				OwnerID:   "", // vai ser preenchido na hora da compra
-			}
-			pool = append(pool, instance)
+			pool = append(pool, newInstance(data))
 		}
 	}
 	return pool
 }
 
+// monta uma instância nova a partir da definição do catálogo
+func newInstance(data models.CardData) models.Tanque {
+	return models.Tanque{
+		ID:         uuid.New().String(), // gera id unico, importante pro ledger
+		Modelo:     data.Modelo,
+		Raridade:   data.Raridade,
+		Categoria:  data.Categoria,
+		Vida:       data.Vida,
+		Ataque:     data.Ataque,
+		Blindagem:  data.Blindagem,
+		Velocidade: data.Velocidade,
+		Habilidade: data.Habilidade,
+		Edicao:     data.Edicao,
+		Timestamp:  time.Now().Unix(),
+		OwnerID:    "", // vai ser preenchido na hora da compra
+	}
+}
+
+// retorna a próxima raridade (false se já for a mais alta)
+func NextRarity(rarity string) (string, bool) {
+	for i, r := range rarityOrder {
+		if r == rarity && i+1 < len(rarityOrder) {
+			return rarityOrder[i+1], true
+		}
+	}
+	return "", false
+}
+
+// procura a definição de um modelo no catálogo
+func (cd *CardDB) FindByModel(modelo string) (models.CardData, bool) {
+	for _, data := range cd.definitions {
+		if data.Modelo == modelo {
+			return data, true
+		}
+	}
+	return models.CardData{}, false
+}
+
+// sorteia um modelo do catálogo com a raridade pedida e cria uma instância nova
+// usado na fabricação (craft), quando o jogador queima duplicatas
+func (cd *CardDB) MintRandom(rarity string) (models.Tanque, error) {
+	var candidates []models.CardData
+	for _, data := range cd.definitions {
+		if data.Raridade == rarity {
+			candidates = append(candidates, data)
+		}
+	}
+	if len(candidates) == 0 {
+		return models.Tanque{}, errors.New("no cards with rarity " + rarity)
+	}
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return newInstance(candidates[r.Intn(len(candidates))]), nil
+}
+
 // embaralha tudo e monta os pacotes
 func (cd *CardDB) CreateBoosters(cardPool []models.Tanque) []models.Booster {
 	// usa rand local para não ter problema em concorrência
@@ -0,0 +1,127 @@
+package combat
+
+import "PlanoZ/internal/models"
+
+// regras de combate entre dois tanques, usando os metadados do cardVault
+
+const (
+	AdvantageBonus = 125 // % do ataque quando a categoria leva vantagem
+	HowitzerBonus  = 125 // % do ataque do obus contra pesados
+	ReactiveArmor  = 75  // % do dano que passa pela blindagem reativa
+	MinDamage      = 5   // todo tiro que acerta causa pelo menos isso
+)
+
+// resultado de um turno, do ponto de vista de quem jogou A
+type TurnResult struct {
+	DanoA    int `json:"dano_a"` // dano que A causou em B
+	DanoB    int `json:"dano_b"` // dano que B causou em A
+	VidaA    int `json:"vida_a"` // vida que sobrou em A
+	VidaB    int `json:"vida_b"`
+	Vencedor int `json:"vencedor"` // 1 = A, 2 = B, 0 = empate
+}
+
+// pedra-papel-tesoura: leve flanqueia pesado, pesado esmaga médio, médio supera leve
+func HasAdvantage(attacker, defender string) bool {
+	switch attacker {
+	case models.CategoryLight:
+		return defender == models.CategoryHeavy
+	case models.CategoryMedium:
+		return defender == models.CategoryLight
+	case models.CategoryHeavy:
+		return defender == models.CategoryMedium
+	}
+	return false
+}
+
+// calcula o dano de um tiro do atacante no defensor
+func Damage(attacker, defender models.Tanque) int {
+	attack := attacker.Ataque
+	if HasAdvantage(attacker.Categoria, defender.Categoria) {
+		attack = attack * AdvantageBonus / 100
+	}
+	if attacker.Habilidade == models.AbilityHowitzer && defender.Categoria == models.CategoryHeavy {
+		attack = attack * HowitzerBonus / 100
+	}
+
+	armor := defender.Blindagem
+	switch attacker.Habilidade {
+	case models.AbilityFlank:
+		armor = 0
+	case models.AbilityPrecision:
+		armor = armor / 2
+	}
+
+	dmg := attack - armor
+	if defender.Habilidade == models.AbilityReactiveArmor {
+		dmg = dmg * ReactiveArmor / 100
+	}
+	if dmg < MinDamage {
+		dmg = MinDamage
+	}
+	return dmg
+}
+
+// diz se A atira antes de B (0 = simultaneo)
+func firstShooter(a, b models.Tanque) int {
+	quickA := a.Habilidade == models.AbilityQuickReload
+	quickB := b.Habilidade == models.AbilityQuickReload
+	if quickA != quickB {
+		if quickA {
+			return 1
+		}
+		return 2
+	}
+	switch {
+	case a.Velocidade > b.Velocidade:
+		return 1
+	case b.Velocidade > a.Velocidade:
+		return 2
+	}
+	return 0
+}
+
+// resolve um confronto de um turno entre as cartas jogadas
+// quem é mais rápido atira primeiro, e se destruir o outro não leva o troco
+func ResolveTurn(a, b models.Tanque) TurnResult {
+	res := TurnResult{VidaA: a.Vida, VidaB: b.Vida}
+
+	shootA := func() {
+		res.DanoA = Damage(a, b)
+		res.VidaB -= res.DanoA
+	}
+	shootB := func() {
+		res.DanoB = Damage(b, a)
+		res.VidaA -= res.DanoB
+	}
+
+	switch firstShooter(a, b) {
+	case 1:
+		shootA()
+		if res.VidaB > 0 {
+			shootB()
+		}
+	case 2:
+		shootB()
+		if res.VidaA > 0 {
+			shootA()
+		}
+	default:
+		shootA()
+		shootB()
+	}
+
+	if res.VidaA < 0 {
+		res.VidaA = 0
+	}
+	if res.VidaB < 0 {
+		res.VidaB = 0
+	}
+
+	switch {
+	case res.VidaA > res.VidaB:
+		res.Vencedor = 1
+	case res.VidaB > res.VidaA:
+		res.Vencedor = 2
+	}
+	return res
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"PlanoZ/internal/blockchain"
+	"PlanoZ/internal/models"
+	"PlanoZ/internal/utils/combat"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// batalha resolvida no host (o servidor que recebeu o aceite assinado do desafio), não no cliente
+// cada jogador entra com as cartas mais fortes que tem no ledger e elas se enfrentam
+// turno a turno pelas regras do combat; ganha quem levar mais turnos
+// no fim o host registra o BR assinado com a chave do nó (o cliente não registra nada)
+
+const (
+	BattleRounds    = 3               // turnos por batalha (limitado pelas cartas de quem tem menos)
+	BattleTurnDelay = 2 * time.Second // intervalo entre turnos, pros clientes acompanharem
+	BattleRecordTTL = 10 * time.Minute // quanto tempo a batalha encerrada fica pra consulta (/battle/register)
+)
+
+const (
+	BatalhaEmAndamento = "em_andamento"
+	BatalhaEncerrada   = "encerrada"
+)
+
+// começa a batalha nesse servidor, avisa os dois jogadores e resolve em background
+func (s *Server) startBattle(id, jogador1, jogador2 string) (*models.Batalha, error) {
+	s.muBatalhas.Lock()
+	if _, exists := s.batalhas[id]; exists {
+		s.muBatalhas.Unlock()
+		return nil, fmt.Errorf("batalha %s já existe", id)
+	}
+	b := &models.Batalha{
+		ID:         id,
+		Jogador1:   jogador1,
+		Jogador2:   jogador2,
+		ServidorJ1: s.ID,
+		Estado:     BatalhaEmAndamento,
+	}
+	s.batalhas[id] = b
+	s.muBatalhas.Unlock()
+
+	// o drainGames do desligamento espera enquanto ela estiver aqui
+	s.muBatalhasPeer.Lock()
+	s.batalhasPeer[id] = models.PeerBattleInfo{BattleID: id, HostAPI: s.APIHost, PlayerID: jogador1}
+	s.muBatalhasPeer.Unlock()
+
+	s.notificarJogadoresBatalha(b, models.NotifInicioBatalha, models.RespostaInicioBatalha{
+		Mensagem:  jogador1 + " x " + jogador2,
+		IdBatalha: id,
+	})
+
+	go s.runBattle(b)
+	return b, nil
+}
+
+// as cartas que vão pra batalha: as mais fortes primeiro (ataque + vida), empate pelo id
+func battleDeck(cards []models.Tanque) []models.Tanque {
+	sort.Slice(cards, func(i, j int) bool {
+		pi, pj := cards[i].Ataque+cards[i].Vida, cards[j].Ataque+cards[j].Vida
+		if pi != pj {
+			return pi > pj
+		}
+		return cards[i].ID < cards[j].ID
+	})
+	if len(cards) > BattleRounds {
+		cards = cards[:BattleRounds]
+	}
+	return cards
+}
+
+func (s *Server) runBattle(b *models.Batalha) {
+	state := s.Blockchain.PendingState()
+	deck1 := battleDeck(state.CardsOf(b.Jogador1))
+	deck2 := battleDeck(state.CardsOf(b.Jogador2))
+
+	rounds := len(deck1)
+	if len(deck2) < rounds {
+		rounds = len(deck2)
+	}
+
+	vitorias1, vitorias2 := 0, 0
+	var cartas []string
+	for i := 0; i < rounds; i++ {
+		time.Sleep(BattleTurnDelay)
+
+		c1, c2 := deck1[i], deck2[i]
+		res := combat.ResolveTurn(c1, c2)
+		switch res.Vencedor {
+		case 1:
+			vitorias1++
+		case 2:
+			vitorias2++
+		}
+		cartas = append(cartas, c1.ID, c2.ID)
+
+		s.notificarJogadoresBatalha(b, models.NotifResultadoTurno, models.NotifBatalhaPayload{
+			IdBatalha: b.ID,
+			Resultado: fmt.Sprintf("Turno %d: %s (%s) causou %d, %s (%s) causou %d. Vida: %d x %d",
+				i+1, c1.Modelo, b.Jogador1, res.DanoA, c2.Modelo, b.Jogador2, res.DanoB, res.VidaA, res.VidaB),
+		})
+	}
+
+	// sem carta dos dois lados não tem batalha, e ninguém ganha
+	resultado := fmt.Sprintf("%s %d x %d %s", b.Jogador1, vitorias1, vitorias2, b.Jogador2)
+	vencedor := ""
+	switch {
+	case rounds == 0:
+		resultado = "Batalha cancelada: os dois jogadores precisam ter cartas"
+	case vitorias1 > vitorias2:
+		vencedor = b.Jogador1
+	case vitorias2 > vitorias1:
+		vencedor = b.Jogador2
+	default:
+		resultado += " (empate)"
+	}
+
+	// resultado na chain, assinado por esse servidor
+	txID := ""
+	if rounds > 0 {
+		txID = s.registerBattleResult(b, vencedor, cartas)
+	}
+
+	s.muBatalhas.Lock()
+	b.Estado = BatalhaEncerrada
+	b.Vencedor = vencedor
+	b.Cartas = cartas
+	b.TxID = txID
+	s.muBatalhas.Unlock()
+
+	color.Cyan("⚔️ [Batalha] %s encerrada: %s", b.ID, resultado)
+	s.notificarJogadoresBatalha(b, models.NotifFimBatalha, models.NotifBatalhaPayload{
+		IdBatalha: b.ID,
+		Resultado: resultado,
+		Vencedor:  vencedor,
+	})
+
+	s.muBatalhasPeer.Lock()
+	delete(s.batalhasPeer, b.ID)
+	s.muBatalhasPeer.Unlock()
+
+	time.AfterFunc(BattleRecordTTL, func() {
+		s.muBatalhas.Lock()
+		delete(s.batalhas, b.ID)
+		s.muBatalhas.Unlock()
+	})
+}
+
+// monta o BR com a chave do nó e manda pra mempool; retorna o id da tx (vazio se falhou)
+func (s *Server) registerBattleResult(b *models.Batalha, vencedor string, cartas []string) string {
+	tx, err := blockchain.NewBattleResult(s.ID, s.nodeKey, models.BattleResultPayload{
+		BattleID: b.ID,
+		Winner:   vencedor,
+		Cards:    cartas,
+		Players:  []string{b.Jogador1, b.Jogador2},
+	})
+	if err == nil {
+		err = s.submitTransaction(tx)
+	}
+	if err != nil {
+		color.Red("⚔️ [Batalha] Erro ao registrar resultado de %s: %v", b.ID, err)
+		return ""
+	}
+	color.Green("⚔️ [Batalha] Resultado de %s enviado para Mempool (tx %s)", b.ID, tx.ID)
+	return tx.ID
+}
+
+// avisa os dois jogadores, estejam conectados em qualquer servidor (o canal é do redis)
+func (s *Server) notificarJogadoresBatalha(b *models.Batalha, tipo string, payload interface{}) {
+	for _, id := range []string{b.Jogador1, b.Jogador2} {
+		if info, ok := s.lookupPlayer(id); ok {
+			s.sendToClient(info.ReplyChannel, tipo, payload)
+		}
+	}
+}
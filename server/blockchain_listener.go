@@ -3,44 +3,200 @@ package main
 import (
 	"PlanoZ/internal/blockchain"
 	"PlanoZ/internal/models"
+	"bytes"
+	"context"
+	"encoding/hex"
 	"encoding/json"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/fatih/color"
-	"github.com/gin-gonic/gin"
 )
 
+// listener de blocos: acorda com o evento de bloco da blockchain (sem polling), processa
+// em ordem e guarda em disco o último bloco aplicado (checkpoint). depois de reiniciar,
+// blocos até o checkpoint só reconstroem os índices em memória, sem notificar de novo
+// ninguém. as notificações também são idempotentes no cluster (SETNX no redis), então
+// reprocessar um bloco (reorg, checkpoint perdido, outro servidor) não duplica mensagem
+//
+// finalidade: bloco novo é provisório (índice de cartas + aviso Tx_Provisoria) até ter
+// FINALITY_DEPTH confirmações; só aí saem os efeitos finais (Compra_Sucesso etc.) e o
+// checkpoint anda. se a cadeia for trocada, o que era provisório nos blocos órfãos é desfeito
+
+const (
+	ListenerCheckpointFile = "listener_checkpoint.json" // padrão do LISTENER_CHECKPOINT_FILE
+	ListenerSafetyInterval = 30 * time.Second           // revarre mesmo sem evento (evento pode ser descartado)
+	NotifyDedupPrefix      = "planoz:notified:"
+	NotifyDedupTTL         = 7 * 24 * time.Hour
+	DefaultFinalityDepth   = 3 // confirmações pra um bloco virar final (FINALITY_DEPTH)
+)
+
+// último bloco cujos efeitos finais (notificações) já foram aplicados
+type listenerCheckpoint struct {
+	Height int    `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+type blockListener struct {
+	path       string
+	depth      int
+	checkpoint listenerCheckpoint
+	blocks     []*blockchain.Block // blocos já processados nessa execução (índice = altura)
+}
+
+// FINALITY_DEPTH: 1 = final assim que entra na cadeia (sem fase provisória)
+func loadFinalityDepth() int {
+	depth, err := strconv.Atoi(os.Getenv("FINALITY_DEPTH"))
+	if err != nil || depth < 1 {
+		return DefaultFinalityDepth
+	}
+	return depth
+}
+
+func loadListenerCheckpoint(path string) listenerCheckpoint {
+	var cp listenerCheckpoint
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			color.Yellow("⚠️ [Listener] Não consegui ler o checkpoint %s: %v", path, err)
+		}
+		return cp
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		color.Yellow("⚠️ [Listener] Checkpoint %s corrompido, começando do zero: %v", path, err)
+		return listenerCheckpoint{}
+	}
+	return cp
+}
+
+// grava num temporário e renomeia, pra nunca deixar o arquivo pela metade
+func (l *blockListener) save() {
+	data, _ := json.Marshal(l.checkpoint)
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		color.Red("❌ [Listener] Falha ao salvar checkpoint: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		color.Red("❌ [Listener] Falha ao salvar checkpoint: %v", err)
+	}
+}
+
+// checkpoint apontando pra uma altura já processada nessa execução
+func (l *blockListener) rewindTo(height int) listenerCheckpoint {
+	if height <= 0 || height >= len(l.blocks) {
+		return listenerCheckpoint{}
+	}
+	return listenerCheckpoint{Height: height, Hash: hex.EncodeToString(l.blocks[height].Hash)}
+}
+
 // fica ouvindo a blockchain pra processar as transacoes novas
-// tem que rodar como goroutine no main
-func (s *Server) RunBlockListener() {
-	color.Cyan("🎧 [Listener] Iniciando monitoramento da Blockchain...")
+// tem que rodar como goroutine no main (para quando o ctx é cancelado)
+func (s *Server) RunBlockListener(ctx context.Context) {
+	path := os.Getenv("LISTENER_CHECKPOINT_FILE")
+	if path == "" {
+		path = ListenerCheckpointFile
+	}
+	l := &blockListener{path: path, depth: loadFinalityDepth(), checkpoint: loadListenerCheckpoint(path)}
+	s.finalHeight.Store(int64(l.checkpoint.Height))
+	color.Cyan("🎧 [Listener] Iniciando monitoramento da Blockchain (checkpoint: bloco #%d, finalidade: %d confirmações)", l.checkpoint.Height, l.depth)
 
-	// comeca do 1, pulando o genesis
-	lastProcessedHeight := 0
+	sub := s.Blockchain.Subscribe(16)
+	defer sub.Close()
+	safety := time.NewTicker(ListenerSafetyInterval)
+	defer safety.Stop()
 
 	for {
-		s.Blockchain.MX.Lock()
-		currentHeight := len(s.Blockchain.Ledger)
-		s.Blockchain.MX.Unlock()
-
-		if currentHeight > lastProcessedHeight {
-			// processa tudo que chegou de novo
-			for i := lastProcessedHeight; i < currentHeight; i++ {
-				s.Blockchain.MX.Lock()
-				block := s.Blockchain.Ledger[i]
-				s.Blockchain.MX.Unlock()
-
-				s.processBlock(block)
+		s.catchUpBlocks(l)
+
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-sub.C:
+			if !ok {
+				return
+			}
+		case <-safety.C:
+		}
+	}
+}
+
+// processa do último bloco visto até a ponta atual, em ordem
+func (s *Server) catchUpBlocks(l *blockListener) {
+	s.Blockchain.MX.Lock()
+	ledger := append([]*blockchain.Block(nil), s.Blockchain.Ledger...)
+	s.Blockchain.MX.Unlock()
+
+	// 1. a cadeia foi trocada por baixo (ReplaceChain): desfaz até o ancestral comum
+	for h := range l.blocks {
+		if h >= len(ledger) || !bytes.Equal(ledger[h].Hash, l.blocks[h].Hash) {
+			s.rollbackBlocks(l, h, ledger)
+			break
+		}
+	}
+
+	// 2. blocos novos: aviso provisório se ainda não estiver fundo o bastante
+	for h := len(l.blocks); h < len(ledger); h++ {
+		block := ledger[h]
+
+		// checkpoint de outra cadeia (ex: nó voltou e sincronizou uma cadeia diferente)
+		if h > 0 && h == l.checkpoint.Height && hex.EncodeToString(block.Hash) != l.checkpoint.Hash {
+			color.Yellow("🔀 [Listener] Checkpoint #%d não bate com a cadeia atual, reaplicando a partir dele", h)
+			l.checkpoint = l.rewindTo(h - 1)
+			l.save()
+		}
+
+		if confirmations := len(ledger) - h; h > 0 && h > l.checkpoint.Height && confirmations < l.depth {
+			s.notifyProvisional(h, block, confirmations, l.depth)
+		}
+		l.blocks = append(l.blocks, block)
+	}
+
+	// 3. o que chegou em FINALITY_DEPTH confirmações vira final
+	final := len(ledger) - l.depth
+	for h := l.checkpoint.Height + 1; h <= final; h++ {
+		s.processBlock(ledger[h])
+		l.checkpoint = listenerCheckpoint{Height: h, Hash: hex.EncodeToString(ledger[h].Hash)}
+		l.save()
+	}
+	s.finalHeight.Store(int64(l.checkpoint.Height))
+}
+
+// desfaz os blocos processados a partir de fork (ficaram órfãos)
+func (s *Server) rollbackBlocks(l *blockListener, fork int, ledger []*blockchain.Block) {
+	color.Yellow("🔀 [Listener] Cadeia trocada a partir do bloco #%d, desfazendo %d bloco(s)", fork, len(l.blocks)-fork)
+
+	// txs que continuam na cadeia nova (só mudaram de bloco) não são revertidas
+	kept := make(map[string]bool)
+	for _, block := range ledger[min(fork, len(ledger)):] {
+		for _, tx := range block.Transactions {
+			kept[tx.ID] = true
+		}
+	}
+
+	for h := len(l.blocks) - 1; h >= fork; h-- {
+		block := l.blocks[h]
+		if h <= l.checkpoint.Height {
+			continue
+		}
+		for _, tx := range block.Transactions {
+			if !kept[tx.ID] && tx.Type != models.TxCoinbase {
+				s.notifyReverted(block, tx)
 			}
-			lastProcessedHeight = currentHeight
 		}
+	}
 
-		// espera para não ser muito tudo rápido
-		time.Sleep(1 * time.Second)
+	// reorg mais fundo que a finalidade: efeito final já saiu, só dá pra registrar
+	if l.checkpoint.Height >= fork {
+		color.Red("❌ [Listener] Reorg atingiu bloco final #%d (FINALITY_DEPTH=%d pequeno demais?)", l.checkpoint.Height, l.depth)
+		l.checkpoint = l.rewindTo(fork - 1)
+		l.save()
 	}
+	l.blocks = l.blocks[:fork]
 }
 
-// varre as transações do bloco
+// efeitos finais do bloco: aplica as txs em ordem
 func (s *Server) processBlock(block *blockchain.Block) {
 	// color.Blue("⚙️ [Listener] Processando Bloco #%d com %d transações", block.Nonce, len(block.Transactions))
 
@@ -48,10 +204,78 @@ func (s *Server) processBlock(block *blockchain.Block) {
 		if tx.Type == "GENESIS" {
 			continue
 		}
+		s.processTransaction(tx)
+	}
+}
+
+// jogadores avisados sobre uma tx (mesmos dos process*)
+func txPlayers(tx *models.Transaction) []string {
+	d := tx.Data
+	switch tx.Type {
+	case models.TxTrade:
+		if len(d) >= 2 {
+			return []string{d[0], d[1]}
+		}
+	case models.TxBattleResult:
+		if len(d) >= 3 && d[2] != "" {
+			return []string{d[2]}
+		}
+	case models.TxPurchase, models.TxCraft, models.TxMarketList, models.TxMarketCancel, models.TxMarketBuy:
+		if len(d) >= 1 {
+			return []string{d[0]}
+		}
+	}
+	return nil
+}
+
+// tx entrou num bloco mas ainda não é final
+// a chave de dedupe leva o hash do bloco: se for reminerada em outro bloco avisa de novo
+func (s *Server) notifyProvisional(height int, block *blockchain.Block, confirmations, depth int) {
+	blockHash := hex.EncodeToString(block.Hash)
+	for _, tx := range block.Transactions {
+		for _, player := range txPlayers(tx) {
+			s.notifyPlayerOnce(tx.ID+":"+blockHash, player, models.NotifTxProvisoria, models.NotifTxProvisoriaPayload{
+				Mensagem:     "Transação incluída num bloco, aguardando confirmações",
+				TxID:         tx.ID,
+				Tipo:         tx.Type,
+				Altura:       height,
+				Confirmacoes: confirmations,
+				Necessarias:  depth,
+			})
+		}
+	}
+}
 
-		// roda em goroutine pra nao travar o loop
-		go s.processTransaction(tx)
+// bloco provisório ficou órfão e a tx não está na cadeia nova
+func (s *Server) notifyReverted(block *blockchain.Block, tx *models.Transaction) {
+	blockHash := hex.EncodeToString(block.Hash)
+	for _, player := range txPlayers(tx) {
+		s.notifyPlayerOnce(tx.ID+":"+blockHash, player, models.NotifTxRevertida, models.NotifTxRevertidaPayload{
+			Mensagem: "O bloco com sua transação foi descartado pela rede",
+			TxID:     tx.ID,
+			Tipo:     tx.Type,
+		})
 	}
+	color.Yellow("↩️ [Listener] Tx %s (%s) revertida", tx.ID, tx.Type)
+}
+
+// avisa o jogador uma vez só por (tx, jogador, tipo), no cluster todo
+// todo servidor roda o listener, quem chegar primeiro no SETNX manda
+func (s *Server) notifyPlayerOnce(txID, playerID, tipo string, payload interface{}) {
+	info, ok := s.lookupPlayer(playerID)
+	if !ok {
+		return
+	}
+
+	key := NotifyDedupPrefix + txID + ":" + playerID + ":" + tipo
+	first, err := s.redisClient.SetNX(s.ctx, key, s.ID, NotifyDedupTTL).Result()
+	if err != nil {
+		// sem redis pra deduplicar, melhor avisar repetido do que não avisar
+		color.Yellow("⚠️ [Listener] Falha no dedupe da notificação %s: %v", key, err)
+	} else if !first {
+		return
+	}
+	s.sendToClient(info.ReplyChannel, tipo, payload)
 }
 
 // aplica as mudancas de estado e avisa os players
@@ -63,6 +287,10 @@ func (s *Server) processTransaction(tx *models.Transaction) {
 		s.processTrade(tx)
 	case models.TxBattleResult:
 		s.processBattleResult(tx)
+	case models.TxCraft:
+		s.processCraft(tx)
+	case models.TxMarketList, models.TxMarketCancel, models.TxMarketBuy:
+		s.processMarket(tx)
 	}
 }
 
@@ -75,24 +303,17 @@ func (s *Server) processPurchase(tx *models.Transaction) {
 	userID := tx.Data[0]
 	boosterJson := tx.Data[1]
 
-	// vê se o usuário está nesse server pra notificar
-	s.muPlayers.RLock()
-	info, isLocal := s.playerList[userID]
-	s.muPlayers.RUnlock()
-
-	if isLocal {
-		// parse do json pra mandar com formato correto
-		var booster models.Booster
-		json.Unmarshal([]byte(boosterJson), &booster)
-
-		// avisa no redis
-		s.sendToClient(info.ReplyChannel, "Compra_Sucesso", gin.H{
-			"mensagem": "Sua compra foi confirmada na Blockchain!",
-			"booster":  booster,
-			"tx_id":    tx.ID,
-		})
-		color.Green("💰 [Listener] Compra confirmada para %s (Tx: %s)", userID, tx.ID)
-	}
+	// parse do json pra mandar com formato correto
+	var booster models.Booster
+	json.Unmarshal([]byte(boosterJson), &booster)
+
+	// avisa no redis
+	s.notifyPlayerOnce(tx.ID, userID, models.NotifCompraSucesso, models.NotifCompraSucessoPayload{
+		Mensagem: "Sua compra foi confirmada na Blockchain!",
+		Booster:  booster,
+		TxID:     tx.ID,
+	})
+	color.Green("💰 [Listener] Compra confirmada para %s (Tx: %s)", userID, tx.ID)
 }
 
 // processTrade: [0]User1, [1]User2, [2]Card1, [3]Card2
@@ -108,15 +329,10 @@ func (s *Server) processTrade(tx *models.Transaction) {
 
 	// helper pra notificar
 	notify := func(uid, msg string) {
-		s.muPlayers.RLock()
-		info, ok := s.playerList[uid]
-		s.muPlayers.RUnlock()
-		if ok {
-			s.sendToClient(info.ReplyChannel, "Troca_Confirmada", gin.H{
-				"mensagem": msg,
-				"tx_id":    tx.ID,
-			})
-		}
+		s.notifyPlayerOnce(tx.ID, uid, models.NotifTrocaConfirmada, models.NotifTrocaConfirmadaPayload{
+			Mensagem: msg,
+			TxID:     tx.ID,
+		})
 	}
 
 	notify(u1, "Troca realizada com sucesso na Blockchain!")
@@ -125,24 +341,92 @@ func (s *Server) processTrade(tx *models.Transaction) {
 	color.Green("🤝 [Listener] Troca confirmada entre %s e %s", u1, u2)
 }
 
-// processBattleResult: [0]BattleID, [1]ReporterID, [2]WinnerID, [3]Meta
+// processBattleResult: [0]BattleID, [1]HostID, [2]WinnerID, [3]Meta
 func (s *Server) processBattleResult(tx *models.Transaction) {
 	if len(tx.Data) < 3 {
 		return
 	}
 
 	winnerID := tx.Data[2]
+	if winnerID == "" {
+		return // empate, ninguém ganha token
+	}
+
+	// avisa só quem ganhou
+	s.notifyPlayerOnce(tx.ID, winnerID, models.NotifRankUpdate, models.NotifRankUpdatePayload{
+		Mensagem: "Vitória registrada na Blockchain!",
+		Tokens:   models.BattleReward,
+		TxID:     tx.ID,
+	})
+	color.Yellow("🏆 [Listener] Vitória registrada para %s", winnerID)
+}
+
+// processCraft: [0]UserID, [1]BurnedIDs, [2]MintedCard
+func (s *Server) processCraft(tx *models.Transaction) {
+	if len(tx.Data) < 3 {
+		return
+	}
+
+	userID := tx.Data[0]
 
-	// avisa só quem ganhou 
-	s.muPlayers.RLock()
-	info, ok := s.playerList[winnerID]
-	s.muPlayers.RUnlock()
+	var burned []string
+	var minted models.Tanque
+	json.Unmarshal([]byte(tx.Data[1]), &burned)
+	json.Unmarshal([]byte(tx.Data[2]), &minted)
 
-	if ok {
-		s.sendToClient(info.ReplyChannel, "Rank_Update", gin.H{
-			"mensagem": "Vitória registrada na Blockchain!",
-			"tx_id":    tx.ID,
+	s.notifyPlayerOnce(tx.ID, userID, models.NotifCraftSucesso, models.NotifCraftSucessoPayload{
+		Mensagem:  "Fabricação confirmada na Blockchain!",
+		Queimadas: burned,
+		Carta:     minted,
+		TxID:      tx.ID,
+	})
+	color.Magenta("🔨 [Listener] %s fabricou %s (%s)", userID, minted.Modelo, minted.Raridade)
+}
+
+// processMarket: ML [0]Seller, [1]CardID, [2]Price | MC/MB [0]UserID, [1]ListingID
+func (s *Server) processMarket(tx *models.Transaction) {
+	notify := func(uid, tipo string, payload interface{}) {
+		s.notifyPlayerOnce(tx.ID, uid, tipo, payload)
+	}
+
+	switch tx.Type {
+	case models.TxMarketList:
+		notify(tx.Data[0], models.NotifMercadoAnunciado, models.NotifMercadoAnuncioPayload{
+			Mensagem:  "Anúncio publicado no mercado!",
+			ListingID: tx.ID,
+			CardID:    tx.Data[1],
+			TxID:      tx.ID,
+		})
+		color.Cyan("🏷️  [Listener] %s anunciou a carta %s por %s tokens", tx.Data[0], tx.Data[1], tx.Data[2])
+
+	case models.TxMarketCancel:
+		notify(tx.Data[0], models.NotifMercadoCancelado, models.NotifMercadoAnuncioPayload{
+			Mensagem:  "Anúncio cancelado.",
+			ListingID: tx.Data[1],
+			TxID:      tx.ID,
 		})
+
+	case models.TxMarketBuy:
+		// o anúncio sumiu do estado, então busca a tx que criou ele
+		listingTx, ok := s.Blockchain.FindTransaction(tx.Data[1])
+		if !ok || len(listingTx.Data) < 3 {
+			return
+		}
+		seller, cardID, price := listingTx.Data[0], listingTx.Data[1], listingTx.Data[2]
+		card := s.Blockchain.State().Cards[cardID]
+
+		notify(tx.Data[0], models.NotifMercadoComprado, models.NotifMercadoCompradoPayload{
+			Mensagem: "Compra no mercado confirmada na Blockchain!",
+			Carta:    card,
+			Preco:    price,
+			TxID:     tx.ID,
+		})
+		notify(seller, models.NotifMercadoVendido, models.NotifMercadoVendidoPayload{
+			Mensagem: "Sua carta foi vendida no mercado!",
+			CardID:   cardID,
+			Preco:    price,
+			TxID:     tx.ID,
+		})
+		color.Green("🛒 [Listener] %s comprou %s de %s por %s tokens", tx.Data[0], cardID, seller, price)
 	}
-	color.Yellow("🏆 [Listener] Vitória registrada para %s", winnerID)
 }
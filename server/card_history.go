@@ -0,0 +1,140 @@
+package main
+
+import (
+	"PlanoZ/internal/models"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// proveniência das cartas: todo evento do ledger que envolve um cardID
+// (mint no booster, trocas, batalhas, craft e mercado)
+// as txs vêm do índice byCard do explorer, aqui só vira evento
+
+// eventos que uma tx gera, por carta (formato dos Data igual ao processTransaction)
+func (s *Server) cardEvents(tx *models.Transaction, base models.CardHistoryEvent) map[string][]models.CardHistoryEvent {
+	events := make(map[string][]models.CardHistoryEvent)
+	add := func(cardID string, ev models.CardHistoryEvent) {
+		if cardID != "" {
+			events[cardID] = append(events[cardID], ev)
+		}
+	}
+	d := tx.Data
+
+	switch tx.Type {
+	case models.TxPurchase: // [0]UserID, [1]BoosterJSON
+		if len(d) < 2 {
+			break
+		}
+		var booster models.Booster
+		json.Unmarshal([]byte(d[1]), &booster)
+		for _, card := range booster.Cards {
+			ev := base
+			ev.Event, ev.To = models.CardEventMint, d[0]
+			add(card.ID, ev)
+		}
+
+	case models.TxTrade: // [0]U1, [1]U2, [2]C1, [3]C2, [4]Aceite
+		if len(d) < 4 {
+			break
+		}
+		ev1 := base
+		ev1.Event, ev1.From, ev1.To, ev1.Counterparty = models.CardEventTrade, d[0], d[1], d[1]
+		add(d[2], ev1)
+		ev2 := base
+		ev2.Event, ev2.From, ev2.To, ev2.Counterparty = models.CardEventTrade, d[1], d[0], d[0]
+		add(d[3], ev2)
+
+	case models.TxBattleResult: // [0]BattleID, [1]HostID, [2]Winner, [3]Meta, [4]CardsJSON, [5]J1, [6]J2
+		if len(d) < 7 {
+			break
+		}
+		// as cartas vêm turno a turno: a do jogador1 e depois a do jogador2
+		var cards []string
+		json.Unmarshal([]byte(d[4]), &cards)
+		for i, cardID := range cards {
+			ev := base
+			ev.Event, ev.From, ev.BattleID, ev.Winner = models.CardEventBattle, d[5+i%2], d[0], d[2]
+			add(cardID, ev)
+		}
+
+	case models.TxCraft: // [0]UserID, [1]BurnedIDs, [2]MintedCard
+		if len(d) < 3 {
+			break
+		}
+		var burned []string
+		var minted models.Tanque
+		json.Unmarshal([]byte(d[1]), &burned)
+		json.Unmarshal([]byte(d[2]), &minted)
+		for _, cardID := range burned {
+			ev := base
+			ev.Event, ev.From = models.CardEventBurn, d[0]
+			add(cardID, ev)
+		}
+		ev := base
+		ev.Event, ev.To = models.CardEventCraft, d[0]
+		add(minted.ID, ev)
+
+	case models.TxMarketList: // [0]Seller, [1]CardID, [2]Price
+		if len(d) < 3 {
+			break
+		}
+		ev := base
+		ev.Event, ev.From, ev.Price = models.CardEventMarketList, d[0], d[2]
+		add(d[1], ev)
+
+	case models.TxMarketCancel, models.TxMarketBuy: // [0]UserID, [1]ListingID
+		if len(d) < 2 {
+			break
+		}
+		// carta, vendedor e preço estão na tx do anúncio
+		listing, ok := s.Blockchain.ExplorerTx(d[1])
+		if !ok || len(listing.Tx.Data) < 3 {
+			break
+		}
+		seller, cardID, price := listing.Tx.Data[0], listing.Tx.Data[1], listing.Tx.Data[2]
+
+		ev := base
+		if tx.Type == models.TxMarketCancel {
+			ev.Event, ev.From = models.CardEventMarketCancel, seller
+		} else {
+			ev.Event, ev.From, ev.To, ev.Counterparty, ev.Price = models.CardEventMarketSale, seller, d[0], d[0], price
+		}
+		add(cardID, ev)
+	}
+	return events
+}
+
+// histórico de uma carta + dono atual (do estado do ledger; queimada fica sem dono)
+func (s *Server) cardHistoryOf(cardID string) (models.CardHistoryResponse, bool) {
+	txs := s.Blockchain.ExplorerCardTxs(cardID)
+	if len(txs) == 0 {
+		return models.CardHistoryResponse{}, false
+	}
+
+	resp := models.CardHistoryResponse{CardID: cardID, Events: []models.CardHistoryEvent{}}
+	for _, etx := range txs {
+		base := models.CardHistoryEvent{
+			TxID:      etx.Tx.ID,
+			Height:    etx.Height,
+			BlockHash: etx.BlockHash,
+			Timestamp: etx.Tx.Timestamp,
+		}
+		resp.Events = append(resp.Events, s.cardEvents(etx.Tx, base)[cardID]...)
+	}
+	if card, ok := s.Blockchain.State().Cards[cardID]; ok {
+		resp.Owner = card.OwnerID
+	}
+	return resp, true
+}
+
+// GET /cards/:id/history
+func (s *Server) handleCardHistory(c *gin.Context) {
+	history, ok := s.cardHistoryOf(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Carta sem histórico no ledger"})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
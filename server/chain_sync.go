@@ -0,0 +1,101 @@
+package main
+
+import (
+	"PlanoZ/internal/blockchain"
+	"PlanoZ/internal/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// sincronização da cadeia com os peers (regra da cadeia mais longa)
+
+// pergunta o /health de todos os membros e devolve as respostas de quem está vivo
+func (s *Server) probePeers() map[string]models.HealthCheckResponse {
+	results := make(map[string]models.HealthCheckResponse)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	client := http.Client{Timeout: 1 * time.Second}
+	for id, host := range s.snapshotServerList() {
+		if id == s.ID {
+			continue
+		}
+		wg.Add(1)
+		go func(id, host string) {
+			defer wg.Done()
+			resp, err := client.Get(fmt.Sprintf("http://%s/health", host))
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+
+			var health models.HealthCheckResponse
+			if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&health) != nil {
+				return
+			}
+			s.markSeen(id)
+			mu.Lock()
+			results[id] = health
+			mu.Unlock()
+		}(id, host)
+	}
+	wg.Wait()
+	return results
+}
+
+// se algum peer tiver cadeia maior, baixa e troca a local
+// retorna a maior altura vista nos peers
+func (s *Server) syncChain() int {
+	peers := s.probePeers()
+
+	bestID, bestHeight := "", 0
+	for id, h := range peers {
+		if h.Height > bestHeight {
+			bestID, bestHeight = id, h.Height
+		}
+	}
+
+	if bestHeight <= s.Blockchain.CurrentHeight() {
+		return bestHeight
+	}
+
+	host, ok := s.hostOf(bestID)
+	if !ok {
+		return bestHeight
+	}
+
+	color.Cyan("🔄 [Sync] %s tem cadeia maior (%d blocos), sincronizando...", bestID, bestHeight)
+
+	req, err := s.newNodeRequest(http.MethodGet, fmt.Sprintf("http://%s/blockchain/", host), nil)
+	if err != nil {
+		return bestHeight
+	}
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		color.Red("❌ [Sync] Falha ao baixar cadeia de %s: %v", bestID, err)
+		return bestHeight
+	}
+	defer resp.Body.Close()
+
+	var data struct {
+		Ledger []*blockchain.Block `json:"ledger"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		color.Red("❌ [Sync] Cadeia de %s malformada: %v", bestID, err)
+		return bestHeight
+	}
+
+	if err := s.Blockchain.ReplaceChain(data.Ledger); err != nil {
+		color.Red("❌ [Sync] Cadeia de %s rejeitada: %v", bestID, err)
+		return bestHeight
+	}
+
+	color.Green("✅ [Sync] Cadeia sincronizada com %s (%d blocos)", bestID, len(data.Ledger))
+	return bestHeight
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"PlanoZ/internal/models"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+)
+
+// membership dinamica do cluster
+// servidores novos entram via /cluster/join em algum seed e a lista se espalha
+// por gossip (cada rodada de health puxa a lista de um peer aleatorio)
+// quem para de responder por MemberTTL é removido, quem sai avisa via /cluster/leave
+
+// helpers de acesso a lista de servidores (protegida pelo muServerList)
+
+// copia da lista id -> host, pra iterar sem segurar o lock
+func (s *Server) snapshotServerList() map[string]string {
+	s.muServerList.RLock()
+	defer s.muServerList.RUnlock()
+
+	list := make(map[string]string, len(s.serverList))
+	for id, host := range s.serverList {
+		list[id] = host
+	}
+	return list
+}
+
+// host interno (api do cluster) de um servidor pelo id
+func (s *Server) hostOf(id string) (string, bool) {
+	s.muServerList.RLock()
+	defer s.muServerList.RUnlock()
+	host, ok := s.serverList[id]
+	return host, ok
+}
+
+// host publico (api dos clientes) de um servidor pelo id
+func (s *Server) apiHostOf(id string) (string, bool) {
+	s.muServerList.RLock()
+	defer s.muServerList.RUnlock()
+	host, ok := s.apiHosts[id]
+	return host, ok && host != ""
+}
+
+// adiciona (ou atualiza) um membro, retorna true se for novo
+func (s *Server) addMember(id, host, apiHost string, lastSeen int64) bool {
+	if id == "" || host == "" {
+		return false
+	}
+
+	s.muServerList.Lock()
+	defer s.muServerList.Unlock()
+
+	// ignora noticia velha de quem já saiu
+	if leftAt, left := s.memberLeft[id]; left && lastSeen <= leftAt {
+		return false
+	}
+	delete(s.memberLeft, id)
+
+	_, exists := s.serverList[id]
+	s.serverList[id] = host
+	if apiHost != "" {
+		s.apiHosts[id] = apiHost
+	}
+	if lastSeen > s.memberSeen[id] {
+		s.memberSeen[id] = lastSeen
+	}
+	return !exists
+}
+
+// remove um membro e guarda quando saiu (pra gossip atrasado não trazer de volta)
+func (s *Server) removeMember(id string) {
+	if id == s.ID {
+		return
+	}
+
+	s.muServerList.Lock()
+	delete(s.serverList, id)
+	delete(s.apiHosts, id)
+	delete(s.memberSeen, id)
+	s.memberLeft[id] = time.Now().Unix()
+	s.muServerList.Unlock()
+
+	s.muLiveServers.Lock()
+	delete(s.liveServers, id)
+	s.muLiveServers.Unlock()
+}
+
+// marca que o servidor respondeu agora
+func (s *Server) markSeen(id string) {
+	s.muServerList.Lock()
+	s.memberSeen[id] = time.Now().Unix()
+	s.muServerList.Unlock()
+}
+
+// lista de membros com o last seen de cada um
+func (s *Server) membersView() []models.ClusterMember {
+	s.muServerList.RLock()
+	defer s.muServerList.RUnlock()
+
+	now := time.Now().Unix()
+	members := make([]models.ClusterMember, 0, len(s.serverList))
+	for id, host := range s.serverList {
+		seen := s.memberSeen[id]
+		if id == s.ID {
+			seen = now
+		}
+		members = append(members, models.ClusterMember{ID: id, Host: host, APIHost: s.apiHosts[id], LastSeen: seen})
+	}
+	return members
+}
+
+// junta a lista de outro nó com a nossa (fica com o last seen mais novo)
+func (s *Server) mergeMembers(members []models.ClusterMember) {
+	cutoff := time.Now().Add(-MemberTTL).Unix()
+	for _, m := range members {
+		if m.ID == s.ID || m.LastSeen < cutoff {
+			continue
+		}
+		if s.addMember(m.ID, m.Host, m.APIHost, m.LastSeen) {
+			color.Cyan("🛰️  [Cluster] Novo membro descoberto via gossip: %s (%s)", m.ID, m.Host)
+		}
+	}
+}
+
+// tira quem não responde há mais de MemberTTL
+func (s *Server) pruneMembers() {
+	cutoff := time.Now().Add(-MemberTTL).Unix()
+
+	s.muServerList.RLock()
+	var stale []string
+	for id := range s.serverList {
+		if id != s.ID && s.memberSeen[id] < cutoff {
+			stale = append(stale, id)
+		}
+	}
+	s.muServerList.RUnlock()
+
+	for _, id := range stale {
+		color.Red("🛰️  [Cluster] %s sem resposta há mais de %s, removendo do cluster", id, MemberTTL)
+		s.removeMember(id)
+	}
+}
+
+// entra no cluster pedindo join pros seeds
+// retorna true se algum seed respondeu
+func (s *Server) joinCluster() bool {
+	req := models.ClusterJoinRequest{ID: s.ID, Host: s.Host, APIHost: s.APIHost}
+	body, _ := json.Marshal(req)
+	client := http.Client{Timeout: RequestTimeout}
+
+	// os seeds só aceitam o join se a minha chave já estiver no diretório
+	s.publishNodeKey()
+
+	joined := false
+	for _, seed := range s.seeds {
+		if seed == s.Host {
+			continue
+		}
+
+		httpReq, err := s.newNodeRequest(http.MethodPost, fmt.Sprintf("http://%s/cluster/join", seed), body)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			continue
+		}
+
+		var members models.ClusterMembersResponse
+		err = json.NewDecoder(resp.Body).Decode(&members)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			continue
+		}
+
+		s.mergeMembers(members.Members)
+		joined = true
+		color.Green("🛰️  [Cluster] Entrou no cluster via seed %s (%d membros)", seed, len(members.Members))
+	}
+	return joined
+}
+
+// puxa a lista de membros de um peer vivo aleatorio
+func (s *Server) gossipMembers() {
+	s.muLiveServers.RLock()
+	var peers []string
+	for id, alive := range s.liveServers {
+		if alive && id != s.ID {
+			peers = append(peers, id)
+		}
+	}
+	s.muLiveServers.RUnlock()
+
+	if len(peers) == 0 {
+		return
+	}
+	host, ok := s.hostOf(peers[rand.Intn(len(peers))])
+	if !ok {
+		return
+	}
+
+	req, err := s.newNodeRequest(http.MethodGet, fmt.Sprintf("http://%s/cluster/members", host), nil)
+	if err != nil {
+		return
+	}
+	client := http.Client{Timeout: RequestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var members models.ClusterMembersResponse
+	if err := json.NewDecoder(resp.Body).Decode(&members); err == nil {
+		s.mergeMembers(members.Members)
+	}
+}
+
+// avisa os outros que esse servidor está saindo
+func (s *Server) leaveCluster() {
+	for id, host := range s.snapshotServerList() {
+		if id == s.ID {
+			continue
+		}
+		s.sendToHost(host, "/cluster/leave", models.ClusterLeaveRequest{ID: s.ID})
+	}
+}
+
+// handlers
+
+// POST /cluster/join
+func (s *Server) handleClusterJoin(c *gin.Context) {
+	var req models.ClusterJoinRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == "" || req.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+	if !s.checkSelfMembership(c, req.ID) {
+		return
+	}
+
+	if s.addMember(req.ID, req.Host, req.APIHost, time.Now().Unix()) {
+		color.Green("🛰️  [Cluster] %s entrou no cluster (%s)", req.ID, req.Host)
+	}
+
+	c.JSON(http.StatusOK, models.ClusterMembersResponse{Members: s.membersView()})
+}
+
+// POST /cluster/leave
+func (s *Server) handleClusterLeave(c *gin.Context) {
+	var req models.ClusterLeaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+	if !s.checkSelfMembership(c, req.ID) {
+		return
+	}
+
+	color.Yellow("🛰️  [Cluster] %s saiu do cluster", req.ID)
+	s.removeMember(req.ID)
+
+	// se quem saiu era o líder, reavalia na hora em vez de esperar o próximo health
+	s.muLeader.RLock()
+	wasLeader := s.currentLeader == req.ID
+	s.muLeader.RUnlock()
+	if wasLeader {
+		go s.checkClusterHealth()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "removed"})
+}
+
+// nó só entra ou sai em nome próprio: o id do corpo tem que ser o que assinou a requisição
+// (senão qualquer nó cadastrava membro com host falso ou tirava os outros do cluster)
+func (s *Server) checkSelfMembership(c *gin.Context, id string) bool {
+	if nodeID := c.GetString(NodeIDContextKey); id != nodeID {
+		color.Red("🛰️  [Cluster] %s tentou mudar a membership de %s", nodeID, id)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Nó só pode entrar ou sair em nome próprio"})
+		return false
+	}
+	return true
+}
+
+// GET /cluster/members
+func (s *Server) handleClusterMembers(c *gin.Context) {
+	c.JSON(http.StatusOK, models.ClusterMembersResponse{Members: s.membersView()})
+}
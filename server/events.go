@@ -0,0 +1,217 @@
+package main
+
+import (
+	"PlanoZ/internal/blockchain"
+	"PlanoZ/internal/models"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+)
+
+// barramento de eventos em tempo real (blocos, txs e líder)
+// dashboards e clientes assinam por GET /events (server-sent events) sem passar pelo redis
+
+const (
+	EventSubscriberBuffer = 64               // eventos guardados por assinante lento antes de descartar
+	EventKeepAlive        = 15 * time.Second // comentário vazio pra conexão não cair em proxy
+)
+
+var eventTopics = map[string]bool{
+	models.EventTopicBlocks: true,
+	models.EventTopicTxs:    true,
+	models.EventTopicLeader: true,
+}
+
+type eventSubscriber struct {
+	ch     chan models.StreamEvent
+	topics map[string]bool // vazio = todos
+}
+
+type EventBus struct {
+	subs   map[int]*eventSubscriber
+	nextID int
+	seq    atomic.Uint64 // id dos eventos
+	mx     sync.RWMutex
+}
+
+func newEventBus() *EventBus {
+	return &EventBus{subs: make(map[int]*eventSubscriber)}
+}
+
+// assina os tópicos (nil = todos); devolve o canal e a função pra cancelar
+func (bus *EventBus) Subscribe(topics map[string]bool) (<-chan models.StreamEvent, func()) {
+	bus.mx.Lock()
+	defer bus.mx.Unlock()
+
+	bus.nextID++
+	id := bus.nextID
+	sub := &eventSubscriber{ch: make(chan models.StreamEvent, EventSubscriberBuffer), topics: topics}
+	bus.subs[id] = sub
+
+	return sub.ch, func() {
+		bus.mx.Lock()
+		defer bus.mx.Unlock()
+		if _, ok := bus.subs[id]; ok {
+			delete(bus.subs, id)
+			close(sub.ch)
+		}
+	}
+}
+
+// publica sem bloquear; assinante com buffer cheio perde o evento
+func (bus *EventBus) Publish(topic, eventType string, data interface{}) {
+	ev := models.StreamEvent{
+		ID:        bus.seq.Add(1),
+		Topic:     topic,
+		Type:      eventType,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+	}
+
+	bus.mx.RLock()
+	defer bus.mx.RUnlock()
+	for _, sub := range bus.subs {
+		if len(sub.topics) > 0 && !sub.topics[topic] {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
+
+// repassa os eventos da blockchain pro barramento
+// roda como goroutine no main (para quando o ctx é cancelado)
+func (s *Server) RunEventBridge(ctx context.Context) {
+	sub := s.Blockchain.Subscribe(EventSubscriberBuffer)
+	defer sub.Close()
+
+	color.Cyan("📣 [Eventos] Repassando eventos da blockchain...")
+	for {
+		var ev blockchain.ChainEvent
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			ev = e
+		}
+
+		switch ev.Kind {
+		case blockchain.ChainBlockAdded, blockchain.ChainReplaced:
+			eventType := models.EventBlockAdded
+			if ev.Kind == blockchain.ChainReplaced {
+				eventType = models.EventChainReplaced
+			}
+			s.events.Publish(models.EventTopicBlocks, eventType, blockchain.BlockSummary(ev.Height, ev.Block))
+
+			// no replace o bloco anunciado é só a ponta, mas todos do fork pra frente são novos
+			if ev.Kind == blockchain.ChainReplaced {
+				for i, block := range ev.Adopted {
+					s.publishTxsConfirmed(ev.Fork+i, block)
+				}
+				continue
+			}
+			s.publishTxsConfirmed(ev.Height, ev.Block)
+
+		case blockchain.ChainTxAccepted:
+			s.events.Publish(models.EventTopicTxs, models.EventTxAccepted, models.TxEventData{
+				TxID: ev.Tx.ID,
+				Type: ev.Tx.Type,
+			})
+		}
+	}
+}
+
+// um tx_confirmed pra cada tx do bloco
+func (s *Server) publishTxsConfirmed(height int, block *blockchain.Block) {
+	blockHash := hex.EncodeToString(block.Hash)
+	for _, tx := range block.Transactions {
+		s.events.Publish(models.EventTopicTxs, models.EventTxConfirmed, models.TxEventData{
+			TxID:      tx.ID,
+			Type:      tx.Type,
+			Height:    height,
+			BlockHash: blockHash,
+		})
+	}
+}
+
+// fecha todos os assinantes (as conexões SSE terminam); usado no desligamento do http
+func (bus *EventBus) Close() {
+	bus.mx.Lock()
+	defer bus.mx.Unlock()
+	for id, sub := range bus.subs {
+		delete(bus.subs, id)
+		close(sub.ch)
+	}
+}
+
+// lê ?topics=blocks,txs,leader (vazio = todos)
+func parseEventTopics(raw string) (map[string]bool, error) {
+	topics := make(map[string]bool)
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.TrimSpace(strings.ToLower(t))
+		if t == "" {
+			continue
+		}
+		if !eventTopics[t] {
+			return nil, fmt.Errorf("unknown topic %q", t)
+		}
+		topics[t] = true
+	}
+	return topics, nil
+}
+
+// GET /events?topics=blocks,txs,leader
+// stream SSE: cada evento sai como "event: <tipo>" + "data: <json do StreamEvent>"
+func (s *Server) handleEvents(c *gin.Context) {
+	topics, err := parseEventTopics(c.Query("topics"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, cancel := s.events.Subscribe(topics)
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	keepAlive := time.NewTicker(EventKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return true
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+			return true
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+)
+
+// encaminhamento de rotas só-de-líder
+// seguidor que recebe a requisição repassa pro líder atual (tentando de novo se o
+// líder mudar no meio) e, se não conseguir, devolve um redirect com o endereço do líder
+// tudo cabe em LeaderForwardBudget, abaixo do timeout de 5s do cliente, senão ele desiste
+// antes da resposta e manda de novo
+
+const (
+	ForwardedByHeader    = "X-Forwarded-By"
+	LeaderForwardBudget  = 4 * time.Second // tempo total das tentativas (o cliente espera 5s)
+	LeaderForwardBackoff = 500 * time.Millisecond
+)
+
+// o que aconteceu com uma tentativa de encaminhar
+type forwardResult int
+
+const (
+	forwardDone    forwardResult = iota // resposta do líder já copiada pro cliente
+	forwardRetry                        // não chegou no líder, ou ele recusou sem processar
+	forwardUnknown                      // pode ter chegado e sido processado (timeout, conexão caiu no meio)
+)
+
+// middleware pras rotas que só o líder atende
+func (s *Server) leaderOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.isLeader() {
+			c.Next()
+			return
+		}
+
+		// já veio encaminhado de outro nó e eu também não sou líder: não repassa de novo
+		// (evita loop enquanto as visões de liderança convergem)
+		if c.GetHeader(ForwardedByHeader) != "" {
+			s.redirectToLeader(c)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+			return
+		}
+
+		deadline := time.Now().Add(LeaderForwardBudget)
+		for {
+			if s.isLeader() {
+				// virei líder enquanto tentava, atende aqui mesmo
+				c.Request.Body = io.NopCloser(bytes.NewReader(body))
+				c.Next()
+				return
+			}
+
+			timeout := time.Until(deadline)
+			if timeout > RequestTimeout {
+				timeout = RequestTimeout
+			}
+			switch s.proxyToLeader(c, body, timeout) {
+			case forwardDone:
+				c.Abort()
+				return
+			case forwardUnknown:
+				// POST pode já ter sido aplicado no líder: repetir duplicaria o efeito
+				if !idempotentMethod(c.Request.Method) {
+					color.Red("↪️  [Forward] Líder não respondeu %s a tempo, sem repetir", c.Request.URL.Path)
+					c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "Líder não respondeu, o pedido pode ter sido processado"})
+					return
+				}
+			}
+
+			// líder pode ter mudado, relê o lease e tenta de novo se ainda sobrar tempo
+			// pra uma tentativa depois do backoff
+			if time.Until(deadline) <= 2*LeaderForwardBackoff {
+				break
+			}
+			time.Sleep(LeaderForwardBackoff)
+			s.refreshLeader()
+		}
+
+		color.Red("↪️  [Forward] Não consegui encaminhar %s para o líder, mandando redirect", c.Request.URL.Path)
+		s.redirectToLeader(c)
+	}
+}
+
+// repetir não muda o resultado
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// repassa a requisição pro líder e copia a resposta
+func (s *Server) proxyToLeader(c *gin.Context, body []byte, timeout time.Duration) forwardResult {
+	s.muLeader.RLock()
+	leader := s.currentLeader
+	s.muLeader.RUnlock()
+
+	host, ok := s.hostOf(leader)
+	if leader == "" || !ok {
+		return forwardRetry
+	}
+
+	// vai pela api interna do líder (sem rate limit, com assinatura do nó)
+	url := fmt.Sprintf("http://%s%s", host, c.Request.URL.RequestURI())
+	req, err := http.NewRequest(c.Request.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return forwardRetry
+	}
+	req.Header.Set("Content-Type", c.GetHeader("Content-Type"))
+	req.Header.Set(ForwardedByHeader, s.ID)
+	// assinado, pro líder poder confiar em quem diz que encaminhou
+	if err := s.signRequest(req, body); err != nil {
+		return forwardRetry
+	}
+
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		// não conseguiu nem conectar: o líder não viu nada
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return forwardRetry
+		}
+		return forwardUnknown
+	}
+	defer resp.Body.Close()
+
+	// líder recusou (não é mais líder ou termo velho): tenta de novo com o líder novo
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusConflict ||
+		resp.StatusCode == http.StatusTemporaryRedirect {
+		return forwardRetry
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return forwardUnknown
+	}
+
+	color.Cyan("↪️  [Forward] %s encaminhado para o líder %s (%d)", c.Request.URL.Path, leader, resp.StatusCode)
+	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+	return forwardDone
+}
+
+// responde 307 com o endereço do líder (o cliente segue sozinho)
+func (s *Server) redirectToLeader(c *gin.Context) {
+	s.muLeader.RLock()
+	leader, term := s.currentLeader, s.currentTerm
+	s.muLeader.RUnlock()
+
+	// redirect é pro cliente, então aponta pra api publica do líder
+	host, ok := s.apiHostOf(leader)
+	if leader == "" || !ok {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Nenhum líder eleito no momento"})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("http://%s%s", host, c.Request.URL.RequestURI()))
+	c.AbortWithStatusJSON(http.StatusTemporaryRedirect, gin.H{
+		"error":       "Eu não sou o líder",
+		"leader":      leader,
+		"leader_host": host,
+		"term":        term,
+	})
+}
+
+// lê o dono do lease no redis sem tentar pegar (só atualiza a visão local)
+func (s *Server) refreshLeader() {
+	val, err := s.redisClient.Get(s.ctx, LeaderLeaseKey).Result()
+	if err != nil {
+		return
+	}
+	if holder, term, err := parseLease(val); err == nil {
+		s.setLeader(holder, term)
+	}
+}
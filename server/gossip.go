@@ -0,0 +1,181 @@
+package main
+
+import (
+	"PlanoZ/internal/blockchain"
+	"PlanoZ/internal/models"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+)
+
+// propagação de blocos e transações por gossip
+// quem tem algo novo anuncia só o id (POST /blockchain/inv); o peer responde com o que
+// não tem (getdata) e só isso é enviado. quem recebe algo novo repassa do mesmo jeito,
+// então um bloco ou tx chega em todo mundo mesmo sem conexão direta com a origem.
+// entrega que falha por rede ou 5xx vai pra uma fila e é tentada de novo com backoff
+
+// uma entrega pendente pra um peer (anúncio + envio do dado)
+type gossipDelivery struct {
+	peerID   string
+	kind     string // models.InvBlock | models.InvTx
+	id       string
+	endpoint string      // onde mandar o dado se o peer quiser
+	payload  interface{} // bloco ou tx
+	attempts int
+	next     time.Time
+}
+
+// anuncia um bloco aceito (minerado aqui ou recebido) pros peers, menos pra quem mandou
+func (s *Server) announceBlock(block *blockchain.Block, from string) {
+	s.announce(models.InvBlock, hex.EncodeToString(block.Hash), "/blockchain/block", block, from)
+}
+
+// anuncia uma tx nova na mempool
+func (s *Server) announceTx(tx models.Transaction, from string) {
+	s.announce(models.InvTx, tx.ID, "/blockchain/tx", tx, from)
+}
+
+func (s *Server) announce(kind, id, endpoint string, payload interface{}, from string) {
+	for peerID := range s.livePeers() {
+		if peerID == from {
+			continue
+		}
+		d := &gossipDelivery{peerID: peerID, kind: kind, id: id, endpoint: endpoint, payload: payload}
+		go s.deliver(d)
+	}
+}
+
+// manda o inv e, se o peer quiser, o dado; falha de rede ou 5xx = volta pra fila
+func (s *Server) deliver(d *gossipDelivery) {
+	host, ok := s.hostOf(d.peerID)
+	if !ok {
+		return // saiu do cluster
+	}
+
+	var inv models.InvResponse
+	err := s.postToHost(host, "/blockchain/inv", models.InvRequest{Kind: d.kind, IDs: []string{d.id}}, &inv)
+	if err == nil && len(inv.Want) > 0 {
+		err = s.sendToHost(host, d.endpoint, d.payload)
+	}
+	if err == nil {
+		return
+	}
+	// o peer recebeu e recusou (ex: 406, bloco inválido pra ele): mandar de novo não muda nada
+	// só vale tentar de novo erro de rede ou 5xx
+	var status statusError
+	if errors.As(err, &status) && status.code < 500 {
+		color.Yellow("📡 [Gossip] %s recusou %s %s: %v", d.peerID, d.kind, d.id, err)
+		return
+	}
+
+	d.attempts++
+	if d.attempts >= GossipMaxAttempts {
+		color.Red("📡 [Gossip] Desistindo de enviar %s %s para %s: %v", d.kind, d.id, d.peerID, err)
+		return
+	}
+	// backoff exponencial: 1s, 2s, 4s...
+	d.next = time.Now().Add(GossipRetryInterval << (d.attempts - 1))
+
+	s.muGossip.Lock()
+	s.gossipQueue = append(s.gossipQueue, d)
+	s.muGossip.Unlock()
+}
+
+// tenta de novo as entregas que falharam (goroutine na main, para quando o ctx é cancelado)
+func (s *Server) RunGossipRetry(ctx context.Context) {
+	ticker := time.NewTicker(GossipRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+
+		s.muGossip.Lock()
+		var due, later []*gossipDelivery
+		for _, d := range s.gossipQueue {
+			if now.After(d.next) {
+				due = append(due, d)
+			} else {
+				later = append(later, d)
+			}
+		}
+		s.gossipQueue = later
+		s.muGossip.Unlock()
+
+		for _, d := range due {
+			go s.deliver(d)
+		}
+	}
+}
+
+// handlers (api do cluster)
+
+// POST /blockchain/inv: responde com os ids que eu ainda não tenho
+func (s *Server) handleInv(c *gin.Context) {
+	var req models.InvRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	want := []string{}
+	for _, id := range req.IDs {
+		switch req.Kind {
+		case models.InvBlock:
+			hash, err := hex.DecodeString(id)
+			if err == nil && !s.Blockchain.HasBlock(hash) {
+				want = append(want, id)
+			}
+		case models.InvTx:
+			if !s.Blockchain.HasTransaction(id) {
+				want = append(want, id)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, models.InvResponse{Want: want})
+}
+
+// POST /blockchain/tx: tx repassada por outro servidor
+func (s *Server) handleReceiveTx(c *gin.Context) {
+	var tx models.Transaction
+	if err := c.ShouldBindJSON(&tx); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction format"})
+		return
+	}
+
+	// já tenho: não valida de novo nem repassa
+	if s.Blockchain.HasTransaction(tx.ID) {
+		c.JSON(http.StatusOK, gin.H{"message": "Transaction already known"})
+		return
+	}
+
+	if err := s.Blockchain.AddTransaction(tx); err != nil {
+		color.Red("📡 [Gossip] Tx %s recusada: %v", tx.ID, err)
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+
+	color.Cyan("📡 [Gossip] Tx %s (%s) recebida de %s", tx.ID, tx.Type, c.GetString(NodeIDContextKey))
+	go s.announceTx(tx, c.GetString(NodeIDContextKey))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transaction accepted"})
+}
+
+// joga a tx na mempool local e espalha pro cluster
+func (s *Server) submitTransaction(tx models.Transaction) error {
+	if err := s.Blockchain.AddTransaction(tx); err != nil {
+		return err
+	}
+	go s.announceTx(tx, "")
+	return nil
+}
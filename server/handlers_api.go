@@ -3,7 +3,9 @@ package main
 import (
 	"PlanoZ/internal/blockchain"
 	"PlanoZ/internal/models"
+	"PlanoZ/internal/utils/cardDB"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -17,10 +19,17 @@ import (
 
 // handleHealthCheck: heartbeating
 func (s *Server) handleHealthCheck(c *gin.Context) {
+	s.muLeader.RLock()
+	leader, term := s.currentLeader, s.currentTerm
+	s.muLeader.RUnlock()
+
 	c.JSON(http.StatusOK, models.HealthCheckResponse{
 		Status:   "OK",
 		ServerID: s.ID,
 		IsLeader: s.isLeader(),
+		Leader:   leader,
+		Term:     term,
+		Height:   s.Blockchain.CurrentHeight(),
 	})
 }
 
@@ -28,9 +37,14 @@ func (s *Server) handleHealthCheck(c *gin.Context) {
 
 // avisa para o lider que um player novo conectou
 func (s *Server) handleLeaderConnect(c *gin.Context) {
-	if !s.isLeader() {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Eu não sou o líder"})
-		return
+	// pela api interna o nó já foi autenticado pelo middleware; pela publica,
+	// se veio de outro servidor tem que estar assinada (antes do bind, que consome o corpo)
+	var nodeID string
+	var nodeErr error
+	if id, ok := c.Get(NodeIDContextKey); ok {
+		nodeID = id.(string)
+	} else {
+		nodeID, nodeErr = s.verifyNodeRequest(c)
 	}
 
 	var req models.LeaderConnectRequest
@@ -39,36 +53,55 @@ func (s *Server) handleLeaderConnect(c *gin.Context) {
 		return
 	}
 
-	// atualiza a lista global de players
-	s.muPlayers.Lock()
-	oldInfo, exists := s.playerList[req.PlayerID]
-	s.playerList[req.PlayerID] = models.PlayerInfo{
-		ServerID:     req.ServerID,
-		ServerHost:   req.ServerHost,
-		ReplyChannel: req.ReplyChannel,
+	// só o líder do termo atual registra
+	if !s.checkLeaderTerm(c, req.Term) {
+		return
 	}
-	s.muPlayers.Unlock()
 
-	// loga apenas se for novidade ou troca de server
-	if !exists || oldInfo.ServerID != req.ServerID {
-		color.Green("LÍDER: Player %s registrado no servidor %s", req.PlayerID, req.ServerID)
-		// propaga para os outros servers
-		go s.broadcastToServers("/players/update", s.playerList)
+	// quem atendeu o cliente de fato: se veio encaminhado (ou pela fila de comandos)
+	// é o servidor que assinou a requisição; header sem assinatura não vale
+	entryID := s.ID
+	if nodeErr == nil {
+		entryID = nodeID
+	} else if nodeErr != errNodeAuthMissing {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": nodeErr.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "registered"})
+	if err := s.connectPlayer(req.PlayerID, entryID, req.ReplyChannel); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Falha ao registrar sessão"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "registered", "term": s.leaderTerm()})
 }
 
-// recebe a lista atualizada do lider
-func (s *Server) handlePlayerUpdate(c *gin.Context) {
-	var newList map[string]models.PlayerInfo
-	if err := c.ShouldBindJSON(&newList); err != nil {
-		return
+// grava a sessão no registro e propaga o delta (chamar só no líder)
+func (s *Server) connectPlayer(playerID, entryID, replyChannel string) error {
+	entryHost, _ := s.hostOf(entryID)
+
+	// grava a sessão no registro do redis (com TTL renovado por heartbeat)
+	oldInfo, exists, err := s.registerPlayer(playerID, models.PlayerInfo{
+		ServerID:     entryID,
+		ServerHost:   entryHost,
+		ReplyChannel: replyChannel,
+	})
+	if err != nil {
+		color.Red("LÍDER: Falha ao registrar player %s no Redis: %v", playerID, err)
+		return err
 	}
-	s.muPlayers.Lock()
-	s.playerList = newList
-	s.muPlayers.Unlock()
-	c.JSON(http.StatusOK, gin.H{"status": "updated"})
+
+	info, _ := s.lookupPlayer(playerID)
+
+	// loga e propaga o delta apenas se for novidade ou troca de server/canal
+	if !exists {
+		color.Green("LÍDER: Player %s registrado no servidor %s", playerID, entryID)
+		s.emitPlayerEvent(models.PlayerJoined, playerID, info)
+	} else if oldInfo.ServerID != entryID || oldInfo.ReplyChannel != replyChannel {
+		color.Green("LÍDER: Player %s mudou para o servidor %s", playerID, entryID)
+		s.emitPlayerEvent(models.PlayerMoved, playerID, info)
+	}
+	return nil
 }
 
 // recebe atualizacao de estoque (feature cancelada)
@@ -87,19 +120,64 @@ func (s *Server) handleLeaderBuyCard(c *gin.Context) {
 		return
 	}
 
-	// 1. confere se a assinatura bate
-	if !blockchain.VerifyTransactionRequestSignature(req) {
-		color.Red("COMPRA: Assinatura inválida do cliente %s", req.UserID)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Assinatura inválida"})
+	tx, status, err := s.submitPurchase(req)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 5. avisa o cliente que está sendo processado
+	c.JSON(http.StatusAccepted, models.AsyncResponse{
+		Message: "Transação de compra enviada para processamento",
+		TxID:    tx.ID,
+		Status:  "processing",
+	})
+}
+
+// assinatura, janela de tempo e requestID do request do cliente
+// um request capturado não pode ser reenviado (nem depois da janela nem com o mesmo id)
+func (s *Server) verifyClientRequest(req models.TransactionRequest) (int, error) {
+	if !blockchain.VerifyTransactionRequestSignature(req) {
+		return http.StatusUnauthorized, errors.New("Assinatura inválida")
+	}
+	if err := blockchain.CheckRequestFreshness(req, time.Now()); err != nil {
+		color.Red("REQUEST: Fora da janela (%s): %v", req.UserID, err)
+		return http.StatusBadRequest, errors.New("Request expirado ou sem request_id")
+	}
+	if s.Blockchain.HasRequest(req) {
+		color.Red("REQUEST: request_id %s reutilizado por %s", req.RequestID, req.UserID)
+		return http.StatusConflict, errors.New("Request já utilizado")
+	}
+	return 0, nil
+}
+
+// a chave do request tem que ser a ligada ao userID no ledger (+ mempool)
+// usuário novo passa, e a chave dele fica presa quando a primeira tx entrar
+func checkUserKey(state *blockchain.LedgerState, req models.TransactionRequest) (int, error) {
+	if err := state.CheckKey(req.UserID, req.PublicKey); err != nil {
+		color.Red("REQUEST: Chave não confere com a de %s", req.UserID)
+		return http.StatusForbidden, errors.New("Chave pública não pertence a esse usuário")
+	}
+	return 0, nil
+}
+
+// valida a compra assinada, separa um booster e joga a tx na mempool
+// retorna o status http que descreve o erro (usado também pela fila de comandos)
+func (s *Server) submitPurchase(req models.TransactionRequest) (models.Transaction, int, error) {
+	// 1. confere se a assinatura bate e se o request é novo
+	if status, err := s.verifyClientRequest(req); err != nil {
+		color.Red("COMPRA: Request recusado do cliente %s: %v", req.UserID, err)
+		return models.Transaction{}, status, err
+	}
+	if status, err := checkUserKey(s.Blockchain.PendingState(), req); err != nil {
+		return models.Transaction{}, status, err
+	}
+
 	// 2. ve se tem booster no estoque
 	s.muTrades.Lock()
 	if len(s.Boosters) == 0 {
 		s.muTrades.Unlock()
-		c.JSON(http.StatusGone, gin.H{"error": "Estoque esgotado"})
-		return
+		return models.Transaction{}, http.StatusGone, errors.New("Estoque esgotado")
 	}
 	// pega o primeiro da fila (fifo)
 	booster := s.Boosters[0]
@@ -120,29 +198,28 @@ func (s *Server) handleLeaderBuyCard(c *gin.Context) {
 			string(boosterJson), // o que comprou (json completo)
 			"BOOSTER_PACK",      // metadado
 		},
-		UserData:  []string{req.Payload, fmt.Sprintf("%d", req.Timestamp), req.UserID, string(req.Type)}, // o que o user assinou
+		UserData:  blockchain.RequestSignedData(req), // o que o user assinou
 		PublicKey: req.PublicKey,
 		Signature: req.Signature,
 	}
 
-	// 4. joga pra mempool
-	if err := s.Blockchain.AddTransaction(tx); err != nil {
+	// 4. joga pra mempool; se não entrar o booster volta pro começo da fila
+	// (senão some do estoque, e a fila de comandos ainda tenta de novo com outro)
+	if err := s.submitTransaction(tx); err != nil {
+		s.muTrades.Lock()
+		s.Boosters = append([]models.Booster{booster}, s.Boosters...)
+		s.muTrades.Unlock()
+
 		color.Red("COMPRA: Erro ao adicionar na Mempool: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return tx, http.StatusInternalServerError, err
 	}
 
 	color.Green("COMPRA: Transação %s enviada para Mempool (User: %s)", tx.ID, req.UserID)
-
-	// 5. avisa o cliente que está sendo processado
-	c.JSON(http.StatusAccepted, models.AsyncResponse{
-		Message: "Transação de compra enviada para processamento",
-		TxID:    tx.ID,
-		Status:  "processing",
-	})
+	return tx, http.StatusAccepted, nil
 }
 
-// registra o fim da batalha na chain
+// consulta o registro na chain de uma batalha que terminou
+// quem cria o BR é o host no fim da batalha (battle.go), o cliente não consegue registrar resultado
 func (s *Server) handleRegisterBattle(c *gin.Context) {
 	var req models.TransactionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -157,43 +234,48 @@ func (s *Server) handleRegisterBattle(c *gin.Context) {
 		return
 	}
 
-	// 1. verifica assinatura
-	if !blockchain.VerifyTransactionRequestSignature(req) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Assinatura inválida"})
+	// 1. verifica assinatura e validade do request
+	if status, err := s.verifyClientRequest(req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 2. valida se a batalha rolou mesmo (confiando na assinatura do jogador vencedor)
-
-	// monta transacao BR
-	tx := models.Transaction{
-		ID:        uuid.New().String(),
-		Type:      models.TxBattleResult,
-		Timestamp: time.Now().Unix(),
-		Data: []string{
-			payload.BattleID,
-			req.UserID,     // quem mandou (vencedor ou perdedor)
-			payload.Winner, // quem ganhou
-			"BATTLE_END",
-		},
-		UserData:  []string{req.Payload, fmt.Sprintf("%d", req.Timestamp), req.UserID, string(req.Type)},
-		PublicKey: req.PublicKey,
-		Signature: req.Signature,
+	// 2. só batalha que esse servidor iniciou, com quem jogou
+	s.muBatalhas.Lock()
+	batalha, ok := s.batalhas[payload.BattleID]
+	var estado, txID string
+	if ok {
+		estado, txID = batalha.Estado, batalha.TxID
 	}
+	s.muBatalhas.Unlock()
 
-	if err := s.Blockchain.AddTransaction(tx); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Batalha não foi iniciada por esse servidor"})
+		return
+	}
+	if req.UserID != batalha.Jogador1 && req.UserID != batalha.Jogador2 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Jogador não participou da batalha"})
+		return
+	}
+	if estado != BatalhaEncerrada {
+		c.JSON(http.StatusConflict, gin.H{"error": "Batalha ainda em andamento"})
+		return
+	}
+	if txID == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Batalha terminou sem resultado registrado"})
 		return
 	}
 
 	c.JSON(http.StatusAccepted, models.AsyncResponse{
-		Message: "Resultado de batalha registrado",
-		TxID:    tx.ID,
+		Message: "Resultado de batalha registrado pelo host",
+		TxID:    txID,
 		Status:  "processing",
 	})
 }
 
 // registra troca finalizada na chain
+// o request é do jogador 1 e tem que trazer o aceite assinado do jogador 2 (payload.Accept),
+// senão qualquer um registrava uma troca pegando a carta de outro jogador
 func (s *Server) handleRegisterTrade(c *gin.Context) {
 	var req models.TransactionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -207,12 +289,20 @@ func (s *Server) handleRegisterTrade(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Payload malformado"})
 		return
 	}
-
-	// 1. verifica assinatura
-	if !blockchain.VerifyTransactionRequestSignature(req) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Assinatura inválida"})
+	if payload.Accept == nil || payload.Accept.UserID != payload.UserTarget {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Troca sem o aceite assinado do outro jogador"})
 		return
 	}
+	accept := *payload.Accept
+
+	// 1. verifica assinatura e validade dos dois requests (o de quem registra e o aceite)
+	for _, r := range []models.TransactionRequest{req, accept} {
+		if status, err := s.verifyClientRequest(r); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	acceptJson, _ := json.Marshal(accept)
 
 	// monta transacao TD
 	tx := models.Transaction{
@@ -224,13 +314,28 @@ func (s *Server) handleRegisterTrade(c *gin.Context) {
 			payload.UserTarget, // User 2
 			payload.CardMy,     // Card 1
 			payload.CardTarget, // Card 2
+			string(acceptJson), // aceite do User 2
 		},
-		UserData:  []string{req.Payload, fmt.Sprintf("%d", req.Timestamp), req.UserID, string(req.Type)},
+		UserData:  blockchain.RequestSignedData(req),
 		PublicKey: req.PublicKey,
 		Signature: req.Signature,
 	}
 
-	if err := s.Blockchain.AddTransaction(tx); err != nil {
+	// 2. aplica no estado pendente: chaves dos dois, termos iguais nos dois lados e donos das cartas
+	state := s.Blockchain.PendingState()
+	for _, r := range []models.TransactionRequest{req, accept} {
+		if status, err := checkUserKey(state, r); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := state.Apply(&tx); err != nil {
+		color.Red("TROCA: Rejeitada entre %s e %s: %v", req.UserID, payload.UserTarget, err)
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.submitTransaction(tx); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -242,130 +347,188 @@ func (s *Server) handleRegisterTrade(c *gin.Context) {
 	})
 }
 
-// handlers de gameplay p2p
-
-// handleBattleInitiate: S1 (Host) -> S2 (Peer)
-func (s *Server) handleBattleInitiate(c *gin.Context) {
-	var req models.BattleInitiateRequest
+// queima duplicatas e fabrica uma carta de raridade maior
+func (s *Server) handleCraftCard(c *gin.Context) {
+	var req models.TransactionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Bad request"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato inválido"})
 		return
 	}
 
-	// registra quem é o host e quem é o peer
-	s.muBatalhasPeer.Lock()
-	s.batalhasPeer[req.IdBatalha] = models.PeerBattleInfo{
-		HostAPI:  req.HostServidor,
-		PlayerID: req.IdJogadorLocal, // o jogador conectado AQUI (J2)
+	var payload models.CraftPayload
+	if err := json.Unmarshal([]byte(req.Payload), &payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payload malformado"})
+		return
 	}
-	s.muBatalhasPeer.Unlock()
 
-	// avisa o player 2 via redis que vai começar
-	s.muPlayers.RLock()
-	infoJ2, ok := s.playerList[req.IdJogadorLocal]
-	s.muPlayers.RUnlock()
-
-	if ok {
-		resp := models.RespostaInicioBatalha{
-			Mensagem:  req.IdOponente, // nome do oponente
-			IdBatalha: req.IdBatalha,
-		}
-		s.sendToClient(infoJ2.ReplyChannel, "Inicio_Batalha", resp)
+	// 1. verifica assinatura e validade do request
+	if status, err := s.verifyClientRequest(req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "ack"})
-}
-
-// handleBattleRequestMove: S1 -> S2 (pede jogada)
-func (s *Server) handleBattleRequestMove(c *gin.Context) {
-	var req models.BattleRequestMoveRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	// 2. confere no ledger (+ mempool) se a chave é a dele e se as cartas são dele e são duplicatas
+	state := s.Blockchain.PendingState()
+	if status, err := checkUserKey(state, req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if len(payload.CardIDs) != cardDB.CRAFT_COST {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("São necessárias %d cartas", cardDB.CRAFT_COST)})
+		return
+	}
+	first, ok := state.Cards[payload.CardIDs[0]]
+	if !ok || first.OwnerID != req.UserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Carta não pertence ao jogador"})
 		return
 	}
 
-	//s.muTradesPeer.RLock() 
-	//_, ok := s.batalhasPeer[req.IdBatalha]
-	//s.muTradesPeer.RUnlock()
+	// 3. confere o modelo no catálogo e sorteia a carta nova
+	if _, exists := s.CardDB.FindByModel(first.Modelo); !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Modelo fora do catálogo"})
+		return
+	}
+	next, ok := cardDB.NextRarity(first.Raridade)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Raridade máxima, não dá pra fabricar"})
+		return
+	}
+	minted, err := s.CardDB.MintRandom(next)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	s.notificarClienteBatalha(req.IdBatalha, "Sua_Vez", "Escolha sua carta")
+	burnedJson, _ := json.Marshal(payload.CardIDs)
+	mintedJson, _ := json.Marshal(minted)
 
-	c.JSON(http.StatusOK, gin.H{"status": "waiting_player"})
-}
+	// monta transacao CF
+	tx := models.Transaction{
+		ID:        uuid.New().String(),
+		Type:      models.TxCraft,
+		Timestamp: time.Now().Unix(),
+		Data: []string{
+			req.UserID,         // quem fabricou
+			string(burnedJson), // cartas queimadas
+			string(mintedJson), // carta nova
+		},
+		UserData:  blockchain.RequestSignedData(req),
+		PublicKey: req.PublicKey,
+		Signature: req.Signature,
+	}
 
-// handleBattleSubmitMove: S2 (Peer) -> S1 (Host) (recebe carta do J2)
-func (s *Server) handleBattleSubmitMove(c *gin.Context) {
-	var req models.BattleSubmitMoveRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	// 4. aplica no estado pendente pra validar todas as regras de uma vez
+	if err := state.Apply(&tx); err != nil {
+		color.Red("CRAFT: Rejeitado para %s: %v", req.UserID, err)
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
 
-	s.muBatalhas.Lock()
-	batalha, ok := s.batalhas[req.IdBatalha]
-	s.muBatalhas.Unlock()
-
-	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Batalha não encontrada no Host"})
+	if err := s.submitTransaction(tx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// manda pro canal do player 2
-	select {
-	case batalha.CanalJ2 <- req.Carta:
-		c.JSON(http.StatusOK, gin.H{"status": "received"})
-	case <-time.After(2 * time.Second):
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Timeout processing move"})
-	}
+	color.Green("CRAFT: Transação %s enviada para Mempool (User: %s, %s -> %s)", tx.ID, req.UserID, first.Modelo, minted.Modelo)
+
+	c.JSON(http.StatusAccepted, models.AsyncResponse{
+		Message: "Fabricação enviada para processamento",
+		TxID:    tx.ID,
+		Status:  "processing",
+	})
 }
 
-// handleBattleTurnResult: S1 -> S2 (resultado do turno)
-func (s *Server) handleBattleTurnResult(c *gin.Context) {
-	var req models.BattleTurnResultRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+// handlers de gameplay p2p
+
+// handleBattleStart: batalha só começa com o pedido assinado dos dois jogadores
+// 1. quem desafia manda o pedido sem challenge: o servidor confere e repassa pro oponente
+// 2. o oponente aceita mandando o próprio pedido com o desafio dentro: esse servidor vira o host
+// (o BR paga tokens, então não dá pra começar batalha contra quem não pediu)
+func (s *Server) handleBattleStart(c *gin.Context) {
+	// desligando: só termina as que já estão rolando
+	if s.shuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Servidor desligando"})
 		return
 	}
 
-	// repassa pro cliente J2
-	s.notificarClienteBatalha(req.IdBatalha, "Resultado_Turno", req.Resultado)
-	c.JSON(http.StatusOK, gin.H{"status": "ack"})
-}
-
-// handleBattleEnd: S1 -> S2 (fim de jogo)
-func (s *Server) handleBattleEnd(c *gin.Context) {
-	var req models.BattleEndRequest
+	var req models.TransactionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato inválido"})
+		return
+	}
+	var payload models.BattleStartPayload
+	if req.Type != models.ReqBattleStart || json.Unmarshal([]byte(req.Payload), &payload) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payload malformado"})
+		return
+	}
+	if payload.BattleID == "" || payload.Opponent == "" || payload.Opponent == req.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Batalha precisa de id e de um oponente"})
 		return
 	}
 
-	// notifica J2
-	s.notificarClienteBatalha(req.IdBatalha, "Fim_Batalha", req.Resultado)
-
-	// limpa infos peer
-	s.muBatalhasPeer.Lock()
-	delete(s.batalhasPeer, req.IdBatalha)
-	s.muBatalhasPeer.Unlock()
+	// 1. assinatura, janela e chave de quem pediu
+	state := s.Blockchain.PendingState()
+	if status, err := s.verifyClientRequest(req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if status, err := checkUserKey(state, req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "ended"})
-}
+	// 2. desafio: só repassa, quem decide é o oponente
+	if payload.Challenge == nil {
+		info, ok := s.lookupPlayer(payload.Opponent)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Oponente não está conectado"})
+			return
+		}
+		s.sendToClient(info.ReplyChannel, models.NotifDesafioBatalha, models.NotifDesafioBatalhaPayload{
+			Mensagem:   req.UserID + " desafiou você para uma batalha",
+			IdBatalha:  payload.BattleID,
+			Desafiante: req.UserID,
+			Desafio:    req,
+		})
+		c.JSON(http.StatusAccepted, gin.H{"status": "challenge_sent"})
+		return
+	}
 
-// funcoes auxiliares
-func (s *Server) notificarClienteBatalha(battleID string, tipo string, payload interface{}) {
-	s.muBatalhasPeer.RLock()
-	info, ok := s.batalhasPeer[battleID]
-	s.muBatalhasPeer.RUnlock()
+	// 3. aceite: o desafio tem que ser do oponente, pra essa batalha e contra quem aceitou
+	challenge := *payload.Challenge
+	var terms models.BattleStartPayload
+	if challenge.Type != models.ReqBattleStart || challenge.UserID != payload.Opponent ||
+		json.Unmarshal([]byte(challenge.Payload), &terms) != nil ||
+		terms.BattleID != payload.BattleID || terms.Opponent != req.UserID || terms.Challenge != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Desafio não confere com o aceite"})
+		return
+	}
+	if status, err := s.verifyClientRequest(challenge); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if status, err := checkUserKey(state, challenge); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
 
-	if ok {
-		s.muPlayers.RLock()
-		playerInfo, pOk := s.playerList[info.PlayerID]
-		s.muPlayers.RUnlock()
-		if pOk {
-			s.sendToClient(playerInfo.ReplyChannel, tipo, payload)
-		}
+	// o host resolve a batalha com as cartas do ledger e avisa os dois jogadores
+	if _, err := s.startBattle(payload.BattleID, challenge.UserID, req.UserID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
 	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "started"})
 }
 
 // --- handlers de troca (mesma lógica da batalha) ---
 
 func (s *Server) handleTradeInitiate(c *gin.Context) {
+	if s.shuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Servidor desligando"})
+		return
+	}
+
 	var req models.TradeInitiateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		return
@@ -375,7 +538,7 @@ func (s *Server) handleTradeInitiate(c *gin.Context) {
 	s.tradesPeer[req.IdTroca] = models.PeerTradeInfo{HostAPI: req.HostServidor, PlayerID: req.IdJogadorLocal}
 	s.muTradesPeer.Unlock()
 
-	s.notificarClienteTroca(req.IdTroca, "Inicio_Troca", models.RespostaInicioTroca{Mensagem: req.IdOponente, IdTroca: req.IdTroca})
+	s.notificarClienteTroca(req.IdTroca, models.NotifInicioTroca, models.RespostaInicioTroca{Mensagem: req.IdOponente, IdTroca: req.IdTroca})
 	c.JSON(http.StatusOK, gin.H{"status": "ack"})
 }
 
@@ -384,7 +547,10 @@ func (s *Server) handleTradeRequestCard(c *gin.Context) {
 	if err := c.ShouldBindJSON(&req); err != nil {
 		return
 	}
-	s.notificarClienteTroca(req.IdTroca, "Sua_Vez_Troca", "Escolha carta para trocar")
+	s.notificarClienteTroca(req.IdTroca, models.NotifSuaVezTroca, models.NotifSuaVezPayload{
+		Mensagem: "Escolha carta para trocar",
+		ID:       req.IdTroca,
+	})
 	c.JSON(http.StatusOK, gin.H{"status": "ack"})
 }
 
@@ -416,7 +582,10 @@ func (s *Server) handleTradeResult(c *gin.Context) {
 		return
 	}
 
-	s.notificarClienteTroca(req.IdTroca, "Resultado_Troca", req.CartaRecebida)
+	s.notificarClienteTroca(req.IdTroca, models.NotifResultadoTroca, models.NotifResultadoTrocaPayload{
+		IdTroca:       req.IdTroca,
+		CartaRecebida: req.CartaRecebida,
+	})
 
 	s.muTradesPeer.Lock()
 	delete(s.tradesPeer, req.IdTroca)
@@ -430,9 +599,7 @@ func (s *Server) notificarClienteTroca(tradeID, tipo string, payload interface{}
 	info, ok := s.tradesPeer[tradeID]
 	s.muTradesPeer.RUnlock()
 	if ok {
-		s.muPlayers.RLock()
-		pInfo, pOk := s.playerList[info.PlayerID]
-		s.muPlayers.RUnlock()
+		pInfo, pOk := s.lookupPlayer(info.PlayerID)
 		if pOk {
 			s.sendToClient(pInfo.ReplyChannel, tipo, payload)
 		}
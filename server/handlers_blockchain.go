@@ -2,6 +2,7 @@ package main
 
 import (
 	"PlanoZ/internal/blockchain"
+	"PlanoZ/internal/models"
 	"net/http"
 	"time"
 
@@ -12,23 +13,37 @@ import (
 // retorna a chain inteira (ledger)
 // usado pelo cliente pra ver a blockchain e novos nodes sincronizarem
 func (s *Server) handleGetBlockchain(c *gin.Context) {
+	// copia sob o lock e serializa fora (o json de uma cadeia grande demora)
 	s.Blockchain.MX.Lock()
-	defer s.Blockchain.MX.Unlock()
+	height := s.Blockchain.Height
+	ledger := append([]*blockchain.Block(nil), s.Blockchain.Ledger...)
+	s.Blockchain.MX.Unlock()
 
 	c.JSON(http.StatusOK, gin.H{
-		"height": s.Blockchain.Height,
-		"ledger": s.Blockchain.Ledger,
+		"height": height,
+		"ledger": ledger,
 	})
 }
 
 // retorna o que está pendente na mempool
 func (s *Server) handleGetMempool(c *gin.Context) {
 	s.Blockchain.MX.Lock()
-	defer s.Blockchain.MX.Unlock()
+	mempool := append([]models.Transaction(nil), s.Blockchain.MPool...)
+	s.Blockchain.MX.Unlock()
 
 	c.JSON(http.StatusOK, gin.H{
-		"count":   len(s.Blockchain.MPool),
-		"mempool": s.Blockchain.MPool,
+		"count":   len(mempool),
+		"mempool": mempool,
+	})
+}
+
+// estatísticas por minerador (blocos e recompensas das coinbases)
+// GET /blockchain/miners
+func (s *Server) handleMinerStats(c *gin.Context) {
+	stats := s.Blockchain.MinerStats()
+	c.JSON(http.StatusOK, gin.H{
+		"reward_per_block": models.BlockReward,
+		"miners":           stats,
 	})
 }
 
@@ -41,7 +56,19 @@ func (s *Server) handleReceiveBlock(c *gin.Context) {
 		return
 	}
 
-	color.Cyan("📦 [Blockchain] Recebido bloco de outro nó. Hash: %x...", block.Hash[:4])
+	if len(block.Hash) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid block format"})
+		return
+	}
+
+	// já chegou por outro caminho do gossip, não precisa validar de novo
+	if s.Blockchain.HasBlock(block.Hash) {
+		c.JSON(http.StatusOK, gin.H{"message": "Block already known"})
+		return
+	}
+
+	from := c.GetString(NodeIDContextKey)
+	color.Cyan("📦 [Blockchain] Recebido bloco de %s. Hash: %x...", from, block.Hash[:4])
 
 	// canal pra pegar o resultado da validação
 	resultChan := make(chan error, 1)
@@ -63,9 +90,15 @@ func (s *Server) handleReceiveBlock(c *gin.Context) {
 			if err != nil {
 				color.Red("❌ [Blockchain] Bloco rejeitado: %v", err)
 				c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+
+				// pode ser que eu esteja atrasado, tenta sincronizar com a cadeia maior
+				go s.syncChain()
 			} else {
 				color.Green("✅ [Blockchain] Bloco aceito e adicionado!")
 				c.JSON(http.StatusOK, gin.H{"message": "Block accepted"})
+
+				// repassa pros peers que ainda não têm
+				go s.announceBlock(&block, from)
 			}
 		case <-time.After(5 * time.Second):
 			color.Red("⚠️ [Blockchain] Timeout validando bloco externo")
@@ -76,3 +109,14 @@ func (s *Server) handleReceiveBlock(c *gin.Context) {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Block queue full"})
 	}
 }
+
+// o que aconteceu com uma tx (pendente, minerada com confirmações, recusada ou descartada)
+// GET /tx/:id/status
+func (s *Server) handleTxStatus(c *gin.Context) {
+	status := s.Blockchain.TxStatus(c.Param("id"))
+	if status.Status == models.TxStatusUnknown {
+		c.JSON(http.StatusNotFound, status)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"PlanoZ/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// explorer da blockchain (só leitura, api pública)
+// as consultas vão nos índices do pacote blockchain, sem segurar o MX enquanto monta o json
+
+const (
+	ExplorerDefaultLimit = 20
+	ExplorerMaxLimit     = 100
+)
+
+// lê ?page= e ?limit= (page começa em 1)
+func explorerPaging(c *gin.Context) (page, limit int, ok bool) {
+	page, limit = 1, ExplorerDefaultLimit
+	var err error
+	if v := c.Query("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return 0, 0, false
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return 0, 0, false
+		}
+	}
+	if limit > ExplorerMaxLimit {
+		limit = ExplorerMaxLimit
+	}
+	return page, limit, true
+}
+
+// GET /explorer/blocks?page=&limit= (mais novos primeiro)
+func (s *Server) handleExplorerBlocks(c *gin.Context) {
+	page, limit, ok := explorerPaging(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, s.Blockchain.ExplorerBlocks(page, limit))
+}
+
+// GET /explorer/blocks/:id (altura ou hash)
+func (s *Server) handleExplorerBlock(c *gin.Context) {
+	block, ok := s.Blockchain.ExplorerBlock(strings.ToLower(c.Param("id")))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Block not found"})
+		return
+	}
+	c.JSON(http.StatusOK, block)
+}
+
+// GET /explorer/tx/:id
+func (s *Server) handleExplorerTx(c *gin.Context) {
+	tx, ok := s.Blockchain.ExplorerTx(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+	c.JSON(http.StatusOK, tx)
+}
+
+// GET /explorer/players/:id/txs
+func (s *Server) handleExplorerPlayerTxs(c *gin.Context) {
+	page, limit, ok := explorerPaging(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, s.Blockchain.ExplorerTxsByPlayer(c.Param("id"), page, limit))
+}
+
+// GET /explorer/types/:type/txs (PC, TD, BR, CF, ML, MC, MB, CB)
+func (s *Server) handleExplorerTypeTxs(c *gin.Context) {
+	page, limit, ok := explorerPaging(c)
+	if !ok {
+		return
+	}
+
+	txType := models.TransactionType(strings.ToUpper(c.Param("type")))
+	switch txType {
+	case models.TxPurchase, models.TxTrade, models.TxBattleResult, models.TxCraft,
+		models.TxMarketList, models.TxMarketCancel, models.TxMarketBuy, models.TxCoinbase:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown transaction type"})
+		return
+	}
+	c.JSON(http.StatusOK, s.Blockchain.ExplorerTxsByType(txType, page, limit))
+}
+
+// GET /explorer/cards/:id/txs (proveniência completa da carta, da criação em diante)
+func (s *Server) handleExplorerCardTxs(c *gin.Context) {
+	page, limit, ok := explorerPaging(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, s.Blockchain.ExplorerTxsByCard(c.Param("id"), page, limit))
+}
+
+// GET /explorer/stats
+func (s *Server) handleExplorerStats(c *gin.Context) {
+	c.JSON(http.StatusOK, s.Blockchain.ExplorerStats())
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"PlanoZ/internal/blockchain"
+	"PlanoZ/internal/models"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// handlers do mercado: anúncios pagos com os tokens ganhos em batalha
+// o estado do mercado é todo derivado do ledger (ver blockchain.LedgerState)
+
+// GET /market?modelo=&raridade=&seller=
+func (s *Server) handleListMarket(c *gin.Context) {
+	state := s.Blockchain.State()
+
+	modelo := c.Query("modelo")
+	raridade := c.Query("raridade")
+	seller := c.Query("seller")
+
+	listings := []models.MarketListing{}
+	for _, l := range state.Listings {
+		if modelo != "" && l.Card.Modelo != modelo {
+			continue
+		}
+		if raridade != "" && l.Card.Raridade != raridade {
+			continue
+		}
+		if seller != "" && l.SellerID != seller {
+			continue
+		}
+		listings = append(listings, l)
+	}
+
+	// mais baratos primeiro
+	sort.Slice(listings, func(i, j int) bool {
+		if listings[i].Price != listings[j].Price {
+			return listings[i].Price < listings[j].Price
+		}
+		return listings[i].Timestamp < listings[j].Timestamp
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    len(listings),
+		"listings": listings,
+	})
+}
+
+// GET /market/listings/:id
+func (s *Server) handleGetListing(c *gin.Context) {
+	state := s.Blockchain.State()
+
+	listing, ok := state.Listings[c.Param("id")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Anúncio não encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, listing)
+}
+
+// GET /market/balance/:player
+// o saldo confirmado vem do ledger, o pendente inclui a mempool
+func (s *Server) handleGetBalance(c *gin.Context) {
+	playerID := c.Param("player")
+
+	c.JSON(http.StatusOK, gin.H{
+		"player_id": playerID,
+		"balance":   s.Blockchain.State().Balances[playerID],
+		"pending":   s.Blockchain.PendingState().Balances[playerID],
+	})
+}
+
+// POST /market/list
+func (s *Server) handleMarketList(c *gin.Context) {
+	var payload models.MarketListPayload
+	req, ok := s.bindMarketRequest(c, &payload)
+	if !ok {
+		return
+	}
+
+	tx := newMarketTx(req, models.TxMarketList, req.UserID, payload.CardID, strconv.Itoa(payload.Price))
+	s.submitMarketTx(c, tx, "Anúncio enviado para processamento")
+}
+
+// POST /market/cancel
+func (s *Server) handleMarketCancel(c *gin.Context) {
+	var payload models.MarketListingPayload
+	req, ok := s.bindMarketRequest(c, &payload)
+	if !ok {
+		return
+	}
+
+	tx := newMarketTx(req, models.TxMarketCancel, req.UserID, payload.ListingID)
+	s.submitMarketTx(c, tx, "Cancelamento enviado para processamento")
+}
+
+// POST /market/buy
+func (s *Server) handleMarketBuy(c *gin.Context) {
+	var payload models.MarketListingPayload
+	req, ok := s.bindMarketRequest(c, &payload)
+	if !ok {
+		return
+	}
+
+	tx := newMarketTx(req, models.TxMarketBuy, req.UserID, payload.ListingID)
+	s.submitMarketTx(c, tx, "Compra enviada para processamento")
+}
+
+// faz o parse do request assinado e do payload, já respondendo o erro se tiver
+func (s *Server) bindMarketRequest(c *gin.Context, payload interface{}) (models.TransactionRequest, bool) {
+	var req models.TransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Formato inválido"})
+		return req, false
+	}
+
+	if err := json.Unmarshal([]byte(req.Payload), payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payload malformado"})
+		return req, false
+	}
+
+	if status, err := s.verifyClientRequest(req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return req, false
+	}
+	if status, err := checkUserKey(s.Blockchain.PendingState(), req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return req, false
+	}
+	return req, true
+}
+
+// monta a tx do mercado com o que o usuário assinou
+func newMarketTx(req models.TransactionRequest, txType models.TransactionType, data ...string) models.Transaction {
+	return models.Transaction{
+		ID:        uuid.New().String(),
+		Type:      txType,
+		Timestamp: time.Now().Unix(),
+		Data:      data,
+		UserData:  blockchain.RequestSignedData(req),
+		PublicKey: req.PublicKey,
+		Signature: req.Signature,
+	}
+}
+
+// valida contra o ledger + mempool e joga na mempool
+func (s *Server) submitMarketTx(c *gin.Context, tx models.Transaction, msg string) {
+	if err := s.Blockchain.PendingState().Apply(&tx); err != nil {
+		color.Red("MERCADO: Rejeitado (%s): %v", tx.Type, err)
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.submitTransaction(tx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	color.Green("MERCADO: Transação %s (%s) enviada para Mempool", tx.ID, tx.Type)
+
+	c.JSON(http.StatusAccepted, models.AsyncResponse{
+		Message: msg,
+		TxID:    tx.ID,
+		Status:  "processing",
+	})
+}
@@ -1,28 +1,243 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"PlanoZ/internal/models"
+
+	"github.com/fatih/color"
+	"github.com/redis/go-redis/v9"
 )
 
-func (s *Server) listenRedisGlobal(topico string) {
-	// color.Cyan("Ouvindo tópico Redis: %s", topico)
-	pubsub := s.redisClient.Subscribe(s.ctx, topico)
-	defer pubsub.Close()
+// fila de comandos dos clientes no redis (streams com consumer group)
+// cada comando publicado em "cmd:<topico>" é entregue pra exatamente um servidor
+// do grupo; se ele cair antes do XACK, outro servidor reivindica depois de
+// CommandClaimIdle, e depois de CommandMaxDeliveries tentativas vai pra dead letter
+
+// erro que não adianta tentar de novo (assinatura inválida, estoque esgotado...)
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+// consome o stream do tópico até o ctx ser cancelado (o XREADGROUP bloqueado é interrompido)
+func (s *Server) listenRedisGlobal(ctx context.Context, topico string) {
+	stream := CommandStreamPrefix + topico
+
+	// cria o grupo (e o stream) se ainda não existir
+	err := s.redisClient.XGroupCreateMkStream(s.ctx, stream, CommandGroup, "0").Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		color.Red("Erro ao criar consumer group em %s: %v", stream, err)
+	}
+
+	go s.reclaimCommands(ctx, stream)
+
+	for ctx.Err() == nil {
+		if !s.consumesTopic(topico) {
+			sleepCtx(ctx, 1*time.Second)
+			continue
+		}
+		streams, err := s.redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    CommandGroup,
+			Consumer: s.ID,
+			Streams:  []string{stream, ">"},
+			Count:    10,
+			Block:    5 * time.Second,
+		}).Result()
+		if err != nil {
+			if err != redis.Nil {
+				sleepCtx(ctx, 1*time.Second)
+			}
+			continue
+		}
+
+		for _, st := range streams {
+			for _, msg := range st.Messages {
+				s.handleCommand(stream, topico, msg)
+			}
+		}
+	}
+}
+
+// compra mexe no estoque de boosters, então só o líder consome (uma fila, um estoque)
+// seguidor nem lê: o comando fica no stream até o líder pegar
+func (s *Server) consumesTopic(topico string) bool {
+	return topico != TopicoComprarCarta || s.isLeader()
+}
+
+// processa um comando e faz o XACK se terminou (sucesso ou erro permanente)
+func (s *Server) handleCommand(stream, topico string, msg redis.XMessage) {
+	payload, _ := msg.Values["payload"].(string)
+
+	err := s.processCommand(topico, payload)
+	var perm permanentError
+	if err != nil && !errors.As(err, &perm) {
+		// fica pendente, o reclaim tenta de novo depois
+		color.Red("⚠️  [Comandos] Falha em %s (%s), vai ser reprocessado: %v", msg.ID, topico, err)
+		return
+	}
+
+	s.redisClient.XAck(s.ctx, stream, CommandGroup, msg.ID)
+}
+
+func (s *Server) processCommand(topico, payload string) error {
+	switch topico {
+	case TopicoConectar:
+		var req models.ReqConectar
+		if err := json.Unmarshal([]byte(payload), &req); err != nil || req.PlayerID == "" {
+			return permanentError{errors.New("comando conectar malformado")}
+		}
+		return s.commandConnect(req)
+
+	case TopicoComprarCarta:
+		var req models.ReqComprarCarta
+		if err := json.Unmarshal([]byte(payload), &req); err != nil || req.PlayerID == "" {
+			return permanentError{errors.New("comando comprar_carta malformado")}
+		}
+		return s.commandBuy(req)
+	}
+	return permanentError{fmt.Errorf("tópico desconhecido: %s", topico)}
+}
+
+// registra o player (no líder) e responde no canal dele
+func (s *Server) commandConnect(req models.ReqConectar) error {
+	if s.isLeader() {
+		if err := s.connectPlayer(req.PlayerID, s.ID, req.ReplyChannel); err != nil {
+			return err
+		}
+	} else {
+		s.muLeader.RLock()
+		leader, term := s.currentLeader, s.currentTerm
+		s.muLeader.RUnlock()
+
+		host, ok := s.hostOf(leader)
+		if !ok {
+			return errors.New("nenhum líder eleito")
+		}
+		err := s.sendToHost(host, "/players/connect", models.LeaderConnectRequest{
+			PlayerID:     req.PlayerID,
+			ServerID:     s.ID,
+			ReplyChannel: req.ReplyChannel,
+			Term:         term,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	s.sendToClient(req.ReplyChannel, models.NotifConectado, models.NotifConectadoPayload{
+		Mensagem: "Registrado via fila de comandos",
+		ServerID: s.ID,
+	})
+	return nil
+}
+
+// processa a compra assinada e responde no canal do player
+func (s *Server) commandBuy(req models.ReqComprarCarta) error {
+	// perdeu a liderança depois de ler: fica pendente pro líder novo reivindicar
+	if !s.isLeader() {
+		return errors.New("compra só é processada pelo líder")
+	}
+
+	tx, status, err := s.submitPurchase(req.Request)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			return err
+		}
+		// reentrega de um comando que já entrou na mempool (XACK se perdeu): nada a fazer
+		if status == http.StatusConflict {
+			color.Yellow("🔁 [Comandos] Compra de %s já processada, só confirmando", req.PlayerID)
+			return nil
+		}
+		s.sendToClient(req.ReplyChannel, models.NotifCompraErro, models.NotifCompraErroPayload{Mensagem: err.Error()})
+		return permanentError{err}
+	}
+
+	s.sendToClient(req.ReplyChannel, models.NotifCompraAceita, models.AsyncResponse{
+		Message: "Transação de compra enviada para processamento",
+		TxID:    tx.ID,
+		Status:  "processing",
+	})
+	return nil
+}
+
+// de tempos em tempos pega comandos que ficaram pendentes (servidor caiu ou falhou)
+// e reprocessa, ou manda pra dead letter se já tentou demais
+func (s *Server) reclaimCommands(ctx context.Context, stream string) {
+	topico := strings.TrimPrefix(stream, CommandStreamPrefix)
+	ticker := time.NewTicker(CommandClaimIdle)
+	defer ticker.Stop()
 
 	for {
-		msg, err := pubsub.ReceiveMessage(s.ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !s.consumesTopic(topico) {
+			continue
+		}
+
+		pending, err := s.redisClient.XPendingExt(s.ctx, &redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  CommandGroup,
+			Idle:   CommandClaimIdle,
+			Start:  "-",
+			End:    "+",
+			Count:  20,
+		}).Result()
 		if err != nil {
-			time.Sleep(1 * time.Second)
 			continue
 		}
 
-		
-		if topico == TopicoConectar {
-			var req models.ReqConectar
-			json.Unmarshal([]byte(msg.Payload), &req)
+		for _, p := range pending {
+			msgs, err := s.redisClient.XClaim(s.ctx, &redis.XClaimArgs{
+				Stream:   stream,
+				Group:    CommandGroup,
+				Consumer: s.ID,
+				MinIdle:  CommandClaimIdle,
+				Messages: []string{p.ID},
+			}).Result()
+			if err != nil || len(msgs) == 0 {
+				continue // outro servidor pegou antes
+			}
+			msg := msgs[0]
+
+			if p.RetryCount >= CommandMaxDeliveries {
+				s.deadLetter(stream, msg, p.RetryCount)
+				continue
+			}
+
+			color.Yellow("🔁 [Comandos] Reprocessando %s (%s), tentativa %d", msg.ID, topico, p.RetryCount+1)
+			s.handleCommand(stream, topico, msg)
 		}
 	}
 }
+
+// move o comando pra dead letter e tira ele do pendente
+func (s *Server) deadLetter(stream string, msg redis.XMessage, deliveries int64) {
+	payload, _ := msg.Values["payload"].(string)
+
+	err := s.redisClient.XAdd(s.ctx, &redis.XAddArgs{
+		Stream: CommandDeadLetter,
+		Values: map[string]interface{}{
+			"stream":     stream,
+			"id":         msg.ID,
+			"payload":    payload,
+			"deliveries": deliveries,
+			"server_id":  s.ID,
+		},
+	}).Err()
+	if err != nil {
+		return // tenta de novo no próximo ciclo
+	}
+
+	s.redisClient.XAck(s.ctx, stream, CommandGroup, msg.ID)
+	color.Red("☠️  [Comandos] %s (%s) foi para a dead letter após %d tentativas", msg.ID, stream, deliveries)
+}
@@ -1,39 +1,66 @@
 package main
 
 import (
+	"PlanoZ/internal/models"
+	"context"
 	"fmt"
 	"net/http"
-	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
 // hearbeating para verifificar quais outros servers estão ativos
-func (s *Server) RunHealthChecks() {
-	time.Sleep(5 * time.Second)
+func (s *Server) RunHealthChecks(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(5 * time.Second):
+	}
 
 	ticker := time.NewTicker(HealthCheckInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		s.checkClusterHealth()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkClusterHealth()
+		}
 	}
 }
 
 func (s *Server) checkClusterHealth() {
+	// desligando: já saiu do cluster e soltou o lease, não pode entrar/pegar de novo
+	if s.shuttingDown() {
+		return
+	}
+
 	// lista temporaria para guardar quem respondeu no momento
 	liveNow := make(map[string]bool)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
-	for id, host := range s.serverList {
+	// sozinho no cluster: tenta entrar de novo pelos seeds (podem ter subido depois)
+	s.publishNodeKey() // se o redis estava fora na subida
+	servers := s.snapshotServerList()
+	if len(servers) <= 1 && s.joinCluster() {
+		servers = s.snapshotServerList()
+	}
+
+	for id, host := range servers {
 		wg.Add(1)
 		go func(sid, shost string) {
 			defer wg.Done()
 			// se respondeu, marca como vivo
 			if s.checkServerHealth(shost) {
+				s.markSeen(sid)
 				mu.Lock()
 				liveNow[sid] = true
 				mu.Unlock()
@@ -53,23 +80,20 @@ func (s *Server) checkClusterHealth() {
 	s.liveServers = liveNow
 	s.muLiveServers.Unlock()
 
+	// membership: tira quem sumiu e troca lista com um peer
+	s.pruneMembers()
+	s.gossipMembers()
+
 	// verifica se o líder caiu
 	s.muLeader.RLock()
 	leader := s.currentLeader
 	s.muLeader.RUnlock()
 
-	// se o lider sumiu, faz eleição nova
-	if leader != "" && !liveNow[leader] {
-		color.Red("🚨 [Cluster] Líder %s caiu! Iniciando nova eleição...", leader)
-		// debug:
-		//for _, peer := range []string{"server1", "server2", "server3"} {
-		//s.liveServers[peer] = s.checkServerHealth(peer)
-		//print("Verificando saúde do peer ", peer, "que está", s.liveServers[peer])
-		//}
-		s.electNewLeader(liveNow)
-	} else if leader == "" {
-		s.electNewLeader(liveNow)
+	// o lease no redis é quem decide; aqui só avisa se o líder parou de responder
+	if leader != "" && leader != s.ID && !liveNow[leader] {
+		color.Red("🚨 [Cluster] Líder %s não responde, aguardando o lease expirar...", leader)
 	}
+	s.electNewLeader()
 }
 
 // manda um get /health
@@ -88,91 +112,194 @@ func (s *Server) checkServerHealth(host string) bool {
 	return resp.StatusCode == http.StatusOK
 }
 
-// elege o lider novo baseado em quem está vivo
-func (s *Server) electNewLeader(liveNow map[string]bool) {
-	color.Yellow("\n[Eleição] Iniciando processo de eleição...")
-
-	// Se for a eleição inicial (liveNow == nil), faz health check completo
-	if liveNow == nil {
-		color.Yellow("[Eleição] Eleição inicial - verificando servidores...")
-		liveNow = make(map[string]bool)
-
-		var wg sync.WaitGroup
-		var mu sync.Mutex
-
-		for id, host := range s.serverList {
-			wg.Add(1)
-			go func(id, host string) {
-				defer wg.Done()
-				color.Cyan("[Eleição] Verificando servidor %s em %s", id, host)
-				if s.checkServerHealth(host) {
-					mu.Lock()
-					liveNow[id] = true
-					mu.Unlock()
-					color.Green("[Eleição] ✓ %s está ONLINE", id)
-				} else {
-					color.Red("[Eleição] ✗ %s está OFFLINE", id)
-				}
-			}(id, host)
+// eleição por lease no redis (SET NX PX) com termo como fencing token
+// só quem segura o lease é líder, então partição de rede não gera dois líderes
+// e o líder antigo que volta não toma a liderança de volta enquanto o lease atual valer
+
+// scripts lua pra operar lease e termo de forma atomica (mesmo slot por causa da hash tag)
+var (
+	// se não tem dono, incrementa o termo e pega o lease; retorna "id|termo" do dono atual
+	acquireLeaseScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if cur then
+	return cur
+end
+local term = redis.call('INCR', KEYS[2])
+local val = ARGV[1] .. '|' .. term
+redis.call('SET', KEYS[1], val, 'PX', ARGV[2])
+return val`)
+
+	// renova o lease só se ainda for meu (mesmo id e mesmo termo)
+	renewLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0`)
+
+	// solta o lease só se ainda for meu (mesmo id e mesmo termo)
+	releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+// roda a cada health check: líder renova o lease, seguidor tenta pegar se estiver livre
+func (s *Server) electNewLeader() {
+	s.muLeader.RLock()
+	leading := s.currentLeader == s.ID && time.Now().Before(s.leaseExpiry)
+	term := s.currentTerm
+	s.muLeader.RUnlock()
+
+	if leading {
+		if s.renewLease(term) {
+			return
 		}
-		wg.Wait()
+		color.Red("[Eleição] Perdi o lease do termo %d, deixando a liderança", term)
+		s.setLeader("", term)
+	}
 
-		// Atualiza o mapa global com a descoberta inicial
-		s.muLiveServers.Lock()
-		s.liveServers = liveNow
-		s.muLiveServers.Unlock()
+	holder, newTerm, err := s.acquireLease()
+	if err != nil {
+		color.Red("[Eleição] Erro ao consultar lease no Redis: %v", err)
+		return
 	}
+	s.setLeader(holder, newTerm)
+}
 
-	// Coleta os IDs dos servidores vivos
-	liveIDs := []string{}
-	for id, alive := range liveNow {
-		if alive {
-			liveIDs = append(liveIDs, id)
-		}
+// tenta pegar o lease, retorna quem é o dono atual e o termo dele
+func (s *Server) acquireLease() (string, int64, error) {
+	start := time.Now()
+	res, err := acquireLeaseScript.Run(s.ctx, s.redisClient,
+		[]string{LeaderLeaseKey, LeaderTermKey}, s.ID, LeaderLeaseTTL.Milliseconds()).Text()
+	if err != nil {
+		return "", 0, err
 	}
 
-	color.Yellow("[Eleição] Servidores vivos detectados: %v", liveIDs)
+	holder, term, err := parseLease(res)
+	if err != nil {
+		return "", 0, err
+	}
 
-	// Se a lista está vazia, adiciona a si mesmo
-	if len(liveIDs) == 0 {
-		liveNow[s.ID] = true
-		liveIDs = append(liveIDs, s.ID)
-		color.Yellow("[Eleição] FALLBACK: Nenhum servidor detectado, assumindo liderança solitária")
+	// lease conta a partir de antes do pedido, assim nunca acho que vale mais do que no redis
+	if holder == s.ID {
+		s.muLeader.Lock()
+		s.leaseExpiry = start.Add(LeaderLeaseTTL)
+		s.muLeader.Unlock()
 	}
+	return holder, term, nil
+}
 
-	// Ordena alfabeticamente e escolhe o menor ID
-	sort.Strings(liveIDs)
-	newLeaderID := liveIDs[0]
+// renova o lease do termo atual
+func (s *Server) renewLease(term int64) bool {
+	start := time.Now()
+	val := fmt.Sprintf("%s|%d", s.ID, term)
+	ok, err := renewLeaseScript.Run(s.ctx, s.redisClient,
+		[]string{LeaderLeaseKey}, val, LeaderLeaseTTL.Milliseconds()).Int()
+	if err != nil {
+		// redis fora: continua líder só até o lease local vencer
+		color.Red("[Eleição] Erro ao renovar lease: %v", err)
+		return s.isLeader()
+	}
+	if ok != 1 {
+		return false
+	}
 
-	// Atualiza o líder atual
 	s.muLeader.Lock()
-	oldLeader := s.currentLeader
-	s.currentLeader = newLeaderID
+	s.leaseExpiry = start.Add(LeaderLeaseTTL)
 	s.muLeader.Unlock()
+	return true
+}
 
-	// Atualiza liveServers com os servidores detectados
-	s.muLiveServers.Lock()
-	s.liveServers = liveNow
-	s.muLiveServers.Unlock()
+// desligamento: apaga o lease na hora em vez de esperar o TTL, assim outro nó assume no
+// próximo health check (ou antes, pelo /cluster/leave)
+func (s *Server) releaseLease() {
+	term := s.leaderTerm()
+	val := fmt.Sprintf("%s|%d", s.ID, term)
+	released, err := releaseLeaseScript.Run(s.ctx, s.redisClient, []string{LeaderLeaseKey}, val).Int()
+	if err != nil {
+		color.Red("[Eleição] Erro ao soltar o lease do termo %d: %v", term, err)
+		return
+	}
+	if released == 1 {
+		color.Yellow("[Eleição] Lease do termo %d liberado", term)
+	}
+	s.setLeader("", term)
+}
 
-	// Anuncia mudança de liderança (se houver)
-	if oldLeader != newLeaderID {
-		color.Green("\n========================================")
-		color.Green("👑 NOVO LÍDER ELEITO: %s", s.currentLeader)
-		color.Green("   Candidatos vivos: %v", liveIDs)
-		color.Green("========================================\n")
+// "server1|7" -> ("server1", 7)
+func parseLease(val string) (string, int64, error) {
+	parts := strings.SplitN(val, "|", 2)
+	if len(parts) != 2 {
+		return "", 0, fmt.Errorf("lease malformado: %q", val)
+	}
+	term, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("termo malformado: %q", val)
+	}
+	return parts[0], term, nil
+}
 
-		// Se este servidor é o novo líder
-		if newLeaderID == s.ID {
-			color.Cyan("[Eleição] EU sou o novo líder!")
-		}
-	} else {
-		color.Cyan("[Eleição] Líder mantido: %s", newLeaderID)
+// atualiza o líder conhecido e anuncia se mudou
+func (s *Server) setLeader(leaderID string, term int64) {
+	s.muLeader.Lock()
+	oldLeader, oldTerm := s.currentLeader, s.currentTerm
+	s.currentLeader = leaderID
+	if term > s.currentTerm {
+		s.currentTerm = term
+	}
+	s.muLeader.Unlock()
+
+	if oldLeader == leaderID && oldTerm == term {
+		return
+	}
+	s.events.Publish(models.EventTopicLeader, models.EventLeaderChanged, models.LeaderEventData{Leader: leaderID, Term: term})
+
+	if leaderID == "" {
+		color.Yellow("[Eleição] Sem líder no momento (termo %d)", oldTerm)
+		return
+	}
+
+	color.Green("\n========================================")
+	color.Green("👑 NOVO LÍDER ELEITO: %s (termo %d)", leaderID, term)
+	color.Green("========================================\n")
+
+	if leaderID == s.ID {
+		color.Cyan("[Eleição] EU sou o novo líder!")
 	}
 }
 
+// só é líder quem tem o lease e ele ainda não venceu
 func (s *Server) isLeader() bool {
 	s.muLeader.RLock()
 	defer s.muLeader.RUnlock()
-	return s.currentLeader == s.ID
+	return s.currentLeader == s.ID && time.Now().Before(s.leaseExpiry)
+}
+
+// termo atual conhecido por esse servidor
+func (s *Server) leaderTerm() int64 {
+	s.muLeader.RLock()
+	defer s.muLeader.RUnlock()
+	return s.currentTerm
+}
+
+// usado pelos handlers só-de-líder: rejeita quem não é líder e quem mandou termo velho
+// termo 0 = quem chamou não sabe o termo (ex, cliente), então aceita
+func (s *Server) checkLeaderTerm(c *gin.Context, reqTerm int64) bool {
+	if !s.isLeader() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Eu não sou o líder"})
+		return false
+	}
+
+	term := s.leaderTerm()
+	if reqTerm != 0 && reqTerm < term {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Termo desatualizado",
+			"term":   term,
+			"leader": s.ID,
+		})
+		return false
+	}
+	return true
 }
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// ciclo de vida do servidor: as goroutines de fundo recebem um ctx e param quando ele é
+// cancelado. no SIGTERM/SIGINT o desligamento segue essa ordem:
+//  1. para de aceitar batalha/troca nova e o /ready passa a responder 503
+//  2. solta o lease (se for líder) e avisa os peers que está saindo, pra liderança trocar na hora
+//  3. cancela a mineração em andamento
+//  4. espera as batalhas e trocas em andamento terminarem (até ShutdownDrainTimeout)
+//  5. drena as requisições http das duas apis
+//  6. para as goroutines de fundo e grava mempool + ledger em disco
+
+const (
+	ShutdownDrainTimeout = 30 * time.Second   // batalhas/trocas em andamento
+	ShutdownHTTPTimeout  = 10 * time.Second   // requisições em andamento
+	ShutdownWaitTimeout  = 5 * time.Second    // goroutines de fundo
+	ChainStateFile       = "chain_state.json" // padrão do CHAIN_STATE_FILE
+)
+
+type lifecycle struct {
+	ctx      context.Context // cancelado no passo 6
+	cancel   context.CancelFunc
+	mining   context.Context // cancelado no passo 3
+	stopMine context.CancelFunc
+	stopping atomic.Bool
+
+	wg      sync.WaitGroup
+	closed  bool // depois do passo 6 não sobe goroutine nova
+	servers []*http.Server
+	mx      sync.Mutex
+}
+
+func newLifecycle() *lifecycle {
+	l := &lifecycle{}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	l.mining, l.stopMine = context.WithCancel(l.ctx)
+	return l
+}
+
+// sobe uma goroutine de fundo que o desligamento espera terminar
+func (l *lifecycle) Go(run func(ctx context.Context)) {
+	l.goWith(l.ctx, run)
+}
+
+// idem, mas com o ctx da mineração (cancelado antes de drenar)
+func (l *lifecycle) GoMining(run func(ctx context.Context)) {
+	l.goWith(l.mining, run)
+}
+
+func (l *lifecycle) goWith(ctx context.Context, run func(ctx context.Context)) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	if l.closed {
+		return
+	}
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		run(ctx)
+	}()
+}
+
+// guarda o http.Server pra ser drenado no passo 5
+func (l *lifecycle) addServer(srv *http.Server) {
+	l.mx.Lock()
+	l.servers = append(l.servers, srv)
+	l.mx.Unlock()
+}
+
+func (s *Server) shuttingDown() bool {
+	return s.life.stopping.Load()
+}
+
+// segura a main até chegar SIGTERM/SIGINT e aí desliga na ordem
+func (s *Server) waitForShutdown() {
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-sigCtx.Done()
+	stop() // um segundo Ctrl+C mata na hora
+
+	color.Yellow("\n🛑 [Shutdown] Sinal recebido, desligando %s...", s.ID)
+	s.shutdown()
+	color.Green("👋 [Shutdown] %s desligado", s.ID)
+}
+
+func (s *Server) shutdown() {
+	l := s.life
+
+	// 1. nada novo entra
+	l.stopping.Store(true)
+
+	// 2. liderança e membership
+	if s.isLeader() {
+		s.releaseLease()
+	}
+	s.leaveCluster()
+	color.Yellow("🛑 [Shutdown] Saída anunciada aos peers")
+
+	// 3. mineração
+	l.stopMine()
+	s.Blockchain.CancelMining()
+
+	// 4. batalhas e trocas em andamento
+	s.drainGames(ShutdownDrainTimeout)
+
+	// 5. http
+	l.mx.Lock()
+	servers := append([]*http.Server(nil), l.servers...)
+	l.mx.Unlock()
+
+	httpCtx, cancel := context.WithTimeout(context.Background(), ShutdownHTTPTimeout)
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(httpCtx); err != nil {
+				color.Red("🛑 [Shutdown] API %s não drenou a tempo: %v", srv.Addr, err)
+			}
+		}(srv)
+	}
+	wg.Wait()
+	cancel()
+
+	// 6. goroutines de fundo e estado em disco
+	l.mx.Lock()
+	l.closed = true
+	l.mx.Unlock()
+	l.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(ShutdownWaitTimeout):
+		color.Red("🛑 [Shutdown] Goroutines de fundo não pararam em %s, seguindo assim mesmo", ShutdownWaitTimeout)
+	}
+
+	if err := s.Blockchain.SaveState(chainStatePath()); err != nil {
+		color.Red("🛑 [Shutdown] Erro ao gravar mempool e ledger: %v", err)
+	}
+	s.redisClient.Close()
+}
+
+// espera até não ter batalha nem troca com jogador desse servidor (ou estourar o timeout)
+func (s *Server) drainGames(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for {
+		s.muBatalhasPeer.RLock()
+		battles := len(s.batalhasPeer)
+		s.muBatalhasPeer.RUnlock()
+		s.muTradesPeer.RLock()
+		trades := len(s.tradesPeer)
+		s.muTradesPeer.RUnlock()
+
+		if battles == 0 && trades == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			color.Red("🛑 [Shutdown] %d batalhas e %d trocas ainda abertas, desligando assim mesmo", battles, trades)
+			return
+		}
+		color.Yellow("🛑 [Shutdown] Aguardando %d batalhas e %d trocas terminarem...", battles, trades)
+		time.Sleep(time.Second)
+	}
+}
+
+// CHAIN_STATE_FILE: onde a mempool e o ledger ficam entre reinícios
+func chainStatePath() string {
+	return envString("CHAIN_STATE_FILE", ChainStateFile)
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
-	"bufio"
 	"context"
+	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"PlanoZ/internal/blockchain"
@@ -22,19 +25,48 @@ import (
 
 // configs gerais
 const (
-	// topicos do redis
-	TopicoConectar     = "conectar"
-	TopicoComprarCarta = "comprar_carta"
+	// topicos do redis (fila de comandos em streams "cmd:<topico>")
+	TopicoConectar       = "conectar"
+	TopicoComprarCarta   = "comprar_carta"
+	CommandStreamPrefix  = "cmd:"
+	CommandGroup         = "servidores"
+	CommandDeadLetter    = "cmd:dead"
+	CommandMaxDeliveries = 3
+	CommandClaimIdle     = 30 * time.Second
+
+	// canal de resposta do cliente (stream por jogador, cliente confirma com XACK)
+	ReplyStreamMaxLen = 500            // guarda só as ultimas notificações
+	ReplyStreamTTL    = 24 * time.Hour // jogador que some não deixa stream pra sempre
 
 	// configs de rede
 	HealthCheckInterval = 5 * time.Second
 	RequestTimeout      = 2 * time.Second
+	MemberTTL           = 20 * time.Second // sem health por esse tempo = fora do cluster
+
+	// eleição por lease (mesma hash tag pra cair no mesmo slot do cluster)
+	LeaderLeaseKey = "{planoz:leader}:lease"
+	LeaderTermKey  = "{planoz:leader}:term"
+	LeaderLeaseTTL = 3 * HealthCheckInterval
+
+	// gossip de blocos e txs
+	GossipRetryInterval = 1 * time.Second
+	GossipMaxAttempts   = 5
+
+	// autenticação entre servidores (chaves públicas dos nós ficam num hash no redis)
+	NodeKeysKey         = "planoz:node_keys"
+	NodeIDHeader        = "X-Node-ID"
+	NodeTimestampHeader = "X-Node-Timestamp"
+	NodeSignatureHeader = "X-Node-Signature"
+	NodeNonceHeader     = "X-Node-Nonce"
+	NodeIDContextKey    = "node_id"
+	NodeAuthMaxSkew     = 30 * time.Second
 )
 
 // struct principal do servidor
 type Server struct {
 	ID         string
-	Host       string // ex: "server1:9090"
+	Host       string // api interna do cluster, ex: "server1:9100"
+	APIHost    string // api publica dos clientes, ex: "server1:9090"
 	UDPAddr    string // ex: "server1:8083"
 	IsLeader   bool
 	StartTimer time.Time
@@ -50,15 +82,27 @@ type Server struct {
 
 	// controle de liderança e cluster
 	currentLeader string
-	serverList    map[string]string // id -> host api
+	currentTerm   int64     // termo do líder atual (fencing token)
+	leaseExpiry   time.Time // até quando meu lease vale (se eu for o líder)
+	seeds         []string          // hosts pra pedir join na entrada
+	serverList    map[string]string // id -> host api interna (muda em runtime)
+	apiHosts      map[string]string // id -> host api publica
+	memberSeen    map[string]int64  // id -> ultima vez que respondeu
+	memberLeft    map[string]int64  // id -> quando saiu (evita voltar por gossip velho)
 	liveServers   map[string]bool   // quem está vivo
 	muLeader      sync.RWMutex
+	muServerList  sync.RWMutex
 	muLiveServers sync.RWMutex
 
 	// memória do jogo
-	playerList map[string]models.PlayerInfo // lista de players online
+	playerList map[string]cachedPlayer // cache local do registro de players (fonte é o redis)
 	muPlayers  sync.RWMutex
 
+	// replicação incremental do registro (log no líder, estado de aplicação no seguidor)
+	playerEventLog   playerEventLog
+	playerEventState playerEventState
+	muPlayerEvents   sync.Mutex
+
 	batalhas   map[string]*models.Batalha // batalhas rolando (apenas no host)
 	muBatalhas sync.Mutex
 
@@ -72,8 +116,38 @@ type Server struct {
 	tradesPeer   map[string]models.PeerTradeInfo
 	muTradesPeer sync.RWMutex
 
-	// api engine
-	ginEngine *gin.Engine
+	// última altura com efeitos finais aplicados (listener); o minerador usa pra saber
+	// se precisa de bloco de confirmação
+	finalHeight atomic.Int64
+
+	// entregas de gossip que falharam, esperando retry
+	gossipQueue []*gossipDelivery
+	muGossip    sync.Mutex
+
+	// identidade do nó e chaves dos outros servidores
+	nodeKey          *ecdsa.PrivateKey
+	nodeKeyPublished atomic.Bool
+	nodeKeysDir      string                      // NODE_KEYS_DIR (vazio = hash do redis)
+	nodeKeys         map[string]*ecdsa.PublicKey // cache do diretório no redis
+	nodeDigests      map[string]int64            // digests já aceitos (anti replay)
+	muNodeAuth       sync.RWMutex
+
+	// startup e prontidão
+	startup   StartupConfig
+	started   bool // health checks e eleição já rodando
+	readiness models.ReadinessResponse
+	muReady   sync.RWMutex
+
+	// eventos em tempo real (GET /events)
+	events *EventBus
+
+	// desligamento ordenado (lifecycle.go)
+	life *lifecycle
+
+	// api engines (publica e interna)
+	api           APIConfig
+	publicEngine  *gin.Engine
+	clusterEngine *gin.Engine
 }
 
 // info básica de onde o player está (qual servidor)
@@ -86,10 +160,12 @@ type PlayerInfo struct {
 func main() {
 	// 1. pega configs do ambiente (docker compose)
 	serverID := os.Getenv("SERVER_ID")
-	apiPort := os.Getenv("API_PORT")
 	udpPort := os.Getenv("UDP_PORT")
 	redisAddrs := os.Getenv("REDIS_ADDRS")
-	serverListEnv := os.Getenv("SERVER_LIST")
+	seedsEnv := os.Getenv("SEED_NODES")
+	if seedsEnv == "" {
+		seedsEnv = os.Getenv("SERVER_LIST") // compatibilidade com a config antiga
+	}
 
 	if serverID == "" {
 		serverID = "server-unknown-" + uuid.New().String()
@@ -107,17 +183,11 @@ func main() {
 		color.Green("Conectado ao Redis Cluster!")
 	}
 
-	// 3. parse da lista de servidores pra descoberta inicial
-	sList := make(map[string]string)
-	if serverListEnv != "" {
-		parts := strings.Split(serverListEnv, ",")
-		for _, p := range parts {
-			// p = "server1:9090"
-			hostParts := strings.Split(p, ":")
-			if len(hostParts) >= 1 {
-				sID := hostParts[0] // usa hostname como id
-				sList[sID] = p
-			}
+	// 3. parse dos seeds pra descoberta inicial (ex: "server1:9090,server2:9090")
+	var seeds []string
+	for _, p := range strings.Split(seedsEnv, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			seeds = append(seeds, p)
 		}
 	}
 
@@ -141,11 +211,19 @@ func main() {
 	}
 
 	bc := blockchain.New()
+	apiCfg := loadAPIConfig()
+
+	// identidade do nó (NODE_KEY_FILE mantém a mesma chave entre reinícios)
+	nodeKey, err := loadNodeKey(os.Getenv("NODE_KEY_FILE"))
+	if err != nil {
+		panic(fmt.Sprintf("Falha ao carregar chave do nó: %v", err))
+	}
 
 	// monta a struct do server
 	s := &Server{
 		ID:         serverID,
-		Host:       serverID + ":" + apiPort,
+		Host:       serverID + ":" + apiCfg.ClusterPort,
+		APIHost:    serverID + ":" + apiCfg.PublicPort,
 		UDPAddr:    serverID + ":" + udpPort,
 		StartTimer: time.Now(),
 
@@ -155,92 +233,112 @@ func main() {
 
 		redisClient:  rdb,
 		ctx:          ctx,
-		serverList:   sList,
+		seeds:        seeds,
+		serverList:   map[string]string{serverID: serverID + ":" + apiCfg.ClusterPort},
+		apiHosts:     map[string]string{serverID: serverID + ":" + apiCfg.PublicPort},
+		memberSeen:   make(map[string]int64),
+		memberLeft:   make(map[string]int64),
 		liveServers:  make(map[string]bool),
-		playerList:   make(map[string]models.PlayerInfo),
+		playerList:   make(map[string]cachedPlayer),
+		playerEventState: playerEventState{
+			pending: make(map[uint64]models.PlayerEvent),
+		},
 		batalhas:     make(map[string]*models.Batalha),
 		batalhasPeer: make(map[string]models.PeerBattleInfo),
 		trades:       make(map[string]*models.Troca),
 		tradesPeer:   make(map[string]models.PeerTradeInfo),
+		startup:      loadStartupConfig(),
+		api:          apiCfg,
+		nodeKey:      nodeKey,
+		nodeKeysDir:  os.Getenv("NODE_KEYS_DIR"),
+		nodeKeys:     make(map[string]*ecdsa.PublicKey),
+		nodeDigests:  make(map[string]int64),
+		events:       newEventBus(),
+		life:         newLifecycle(),
+	}
+	s.publishNodeKey()
+
+	// blocos minerados aqui saem com coinbase assinada pela chave do nó
+	bc.SetMiner(s.ID, nodeKey)
+	// e os que chegam só valem com a coinbase assinada pela chave registrada do minerador
+	bc.SetNodeKeys(s.nodeKeyBytes)
+	// e o craft só vale com a carta nova igual à do catálogo
+	bc.SetCardCatalog(cd.FindByModel)
+
+	// mempool e ledger gravados no último desligamento (CHAIN_STATE_FILE)
+	// (depois do SetNodeKeys e SetCardCatalog, a cadeia gravada passa pela mesma validação)
+	if err := bc.LoadState(chainStatePath()); err != nil {
+		color.Red("Estado da blockchain ignorado: %v. Começando do genesis.", err)
 	}
 
-	// 5. goroutines rodando paralelamente
+	// 5. goroutines rodando paralelamente (param no desligamento, ver lifecycle.go)
 
 	// A) loop da blockchain (processa blocos que chegam)
-	go s.Blockchain.RunBlockchainLoop()
+	s.life.Go(s.Blockchain.RunBlockchainLoop)
 
-	// B) ouve redis (conexoes e compras globais)
-	go s.listenRedisGlobal(TopicoConectar)
-	go s.listenRedisGlobal(TopicoComprarCarta)
+	// B) consome a fila de comandos do redis (conexoes e compras globais)
+	s.life.Go(func(ctx context.Context) { s.listenRedisGlobal(ctx, TopicoConectar) })
+	s.life.Go(func(ctx context.Context) { s.listenRedisGlobal(ctx, TopicoComprarCarta) })
 
 	// C) ping UDP
-	go s.RunUDP(udpPort)
+	s.life.Go(func(ctx context.Context) { s.RunUDP(ctx, udpPort) })
 
 	// D) sobe api rest
-	s.ginEngine = s.setupRouter()
-	go s.RunAPI(apiPort)
+	s.publicEngine = s.setupPublicRouter()
+	s.clusterEngine = s.setupClusterRouter()
+	go s.RunAPI("publica", s.publicEngine, s.api.PublicPort)
+	go s.RunAPI("do cluster", s.clusterEngine, s.api.ClusterPort)
 
 	// minerador e listener de blocos
-	go s.RunBlockListener()
-	go s.RunMiner()
+	s.life.Go(s.RunBlockListener)
+	s.life.Go(s.RunEventBridge)
+	s.life.GoMining(s.RunMiner)
+	s.life.Go(s.RunGossipRetry)
 
 	// 6. logs 
 	externalPort := os.Getenv("EXTERNAL_PORT")
 	if externalPort == "" {
-		externalPort = apiPort
+		externalPort = s.api.PublicPort
 	}
 
 	color.Cyan("===========================================")
 	color.Cyan("  PLANO Z - SERVIDOR BLOCKCHAIN")
 	color.Cyan("===========================================")
 	color.White("Server ID:      %s", s.ID)
-	color.White("API Interna:    %s:%s", serverID, apiPort)
+	color.White("API Publica:    %s", s.APIHost)
+	color.White("API Cluster:    %s", s.Host)
 	color.White("API Externa:    localhost:%s", externalPort)
 	color.White("UDP Interna:    %s:%s", serverID, udpPort)
-	color.White("Servidores:     %v", s.serverList)
+	color.White("Seeds:          %v", s.seeds)
+	color.White("Startup:        %s", s.startup.Mode)
 	color.Cyan("===========================================")
 
-	// 7. espera sinal manual (ENTER) para eleição
-	color.Yellow("\nPressione ENTER para iniciar Health Checks e Eleição...")
-	bufio.NewReader(os.Stdin).ReadString('\n')
-
-	// 8. heartbeating
-	go s.RunHealthChecks()
+	// 7. startup: espera ENTER (manual) ou as condições de prontidão (auto)
+	// e sobe health checks + eleição
+	go s.runStartup()
 
-	// 9. espera para detectar servers ativos
-	color.Yellow("Aguardando descoberta de nós (3s)...")
-	time.Sleep(3 * time.Second)
-
-	// 10. mostrar servers conectados e vivos
-	s.muLiveServers.RLock()
-	color.Green("Nós vivos detectados: %d", len(s.liveServers))
-	for id, alive := range s.liveServers {
-		if alive {
-			color.Green("  ✓ %s", id)
-		}
-	}
-	s.muLiveServers.RUnlock()
-
-	// 11. votação do líder
-	color.Yellow("\nIniciando eleição de líder...")
-	s.electNewLeader(nil)
-
-	select {}
+	// 8. fica de pé até SIGTERM/SIGINT e desliga em ordem
+	s.waitForShutdown()
 }
 
 // funcoes de inicializacao
 
-// sobe o gin
-func (s *Server) RunAPI(port string) {
-	color.Green("Iniciando servidor API Gin na porta :%s", port)
+// sobe um gin (api publica ou do cluster) num http.Server que o desligamento consegue drenar
+func (s *Server) RunAPI(name string, engine *gin.Engine, port string) {
+	srv := &http.Server{Addr: ":" + port, Handler: engine}
+	// conexões SSE não terminam sozinhas, fecha os assinantes pro Shutdown não ficar esperando
+	srv.RegisterOnShutdown(s.events.Close)
+	s.life.addServer(srv)
+
+	color.Green("Iniciando API %s na porta :%s", name, port)
 	// ouve em "0.0.0.0:port"
-	if err := s.ginEngine.Run(":" + port); err != nil {
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		panic(fmt.Sprintf("Falha ao iniciar Gin: %v", err))
 	}
 }
 
-// sobe o udp
-func (s *Server) RunUDP(port string) {
+// sobe o udp (fecha o socket quando o ctx é cancelado)
+func (s *Server) RunUDP(ctx context.Context, port string) {
 	// ouve em "0.0.0.0:port"
 	udpAddr, err := net.ResolveUDPAddr("udp", ":"+port)
 	if err != nil {
@@ -253,6 +351,10 @@ func (s *Server) RunUDP(port string) {
 		return
 	}
 	defer udpConn.Close()
+	go func() {
+		<-ctx.Done()
+		udpConn.Close()
+	}()
 
 	color.Green("Servidor UDP ouvindo em :%s", port)
 	s.lidarPing(udpConn)
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// configuração das duas apis http
+// publica (clientes): CORS + limite de requisições por ip
+// interna (cluster): só servidores com chave de nó (ver node_auth.go)
+
+type APIConfig struct {
+	PublicPort  string   // API_PORT
+	ClusterPort string   // CLUSTER_PORT
+	CORSOrigins []string // CORS_ORIGINS: lista separada por virgula, "*" libera tudo
+	RateLimit   float64  // RATE_LIMIT_RPS: requisições por segundo por ip (0 desliga)
+	RateBurst   int      // RATE_LIMIT_BURST: rajada permitida
+}
+
+func loadAPIConfig() APIConfig {
+	var origins []string
+	for _, o := range strings.Split(envString("CORS_ORIGINS", "*"), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+
+	rate, err := strconv.ParseFloat(envString("RATE_LIMIT_RPS", "20"), 64)
+	if err != nil {
+		rate = 20
+	}
+
+	return APIConfig{
+		PublicPort:  envString("API_PORT", "9090"),
+		ClusterPort: envString("CLUSTER_PORT", "9100"),
+		CORSOrigins: origins,
+		RateLimit:   rate,
+		RateBurst:   envInt("RATE_LIMIT_BURST", 40),
+	}
+}
+
+// --- CORS ---
+
+func corsMiddleware(origins []string) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool)
+	for _, o := range origins {
+		if o == "*" {
+			allowAll = true
+		}
+		allowed[o] = true
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin != "" && (allowAll || allowed[origin]) {
+			if allowAll {
+				c.Header("Access-Control-Allow-Origin", "*")
+			} else {
+				c.Header("Access-Control-Allow-Origin", origin)
+				c.Header("Vary", "Origin")
+			}
+			c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			c.Header("Access-Control-Allow-Headers", "Content-Type")
+			c.Header("Access-Control-Max-Age", "600")
+		}
+
+		// preflight não chega nos handlers
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// --- rate limit (token bucket por ip) ---
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+type rateLimiter struct {
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+	mu      sync.Mutex
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	rl := &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+	go rl.cleanup()
+	return rl
+}
+
+// tira um token do balde do ip, false se acabou
+func (rl *rateLimiter) allow(key string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst, lastSeen: now}
+		rl.buckets[key] = b
+	}
+
+	// reabastece pelo tempo que passou
+	b.tokens += now.Sub(b.lastSeen).Seconds() * rl.rate
+	if b.tokens > rl.burst {
+		b.tokens = rl.burst
+	}
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// remove balde de quem sumiu (já estaria cheio de novo)
+func (rl *rateLimiter) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		full := time.Duration(rl.burst/rl.rate*float64(time.Second)) + time.Minute
+		rl.mu.Lock()
+		for key, b := range rl.buckets {
+			if time.Since(b.lastSeen) > full {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+func rateLimitMiddleware(rate float64, burst int) gin.HandlerFunc {
+	if rate <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	rl := newRateLimiter(rate, burst)
+	retryAfter := strconv.Itoa(int(1/rate) + 1)
+
+	return func(c *gin.Context) {
+		if !rl.allow(c.ClientIP()) {
+			c.Header("Retry-After", retryAfter)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Muitas requisições, tente de novo em instantes"})
+			return
+		}
+		c.Next()
+	}
+}
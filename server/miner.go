@@ -1,33 +1,51 @@
 package main
 
 import (
+	"PlanoZ/internal/blockchain"
+	"PlanoZ/internal/models"
+	"context"
+	"math/rand"
 	"time"
 
 	"github.com/fatih/color"
 )
 
-// loop eterno tentando achar bloco, rodando como goroutine na main
-func (s *Server) RunMiner() {
+// sem tx nova, espera isso (+ sorteio) depois do último bloco antes de minerar um vazio
+const ConfirmationBlockDelay = 5 * time.Second
+
+// loop tentando achar bloco, rodando como goroutine na main (para quando o ctx é cancelado)
+func (s *Server) RunMiner(ctx context.Context) {
 	color.Cyan("⛏️  [Miner] Iniciando minerador...")
 
-	time.Sleep(5 * time.Second)
+	if !sleepCtx(ctx, 5*time.Second) {
+		return
+	}
 
-	for {
-		// 1. verifica mempool
+	for ctx.Err() == nil {
+		// 1. verifica mempool (antes descarta o que passou da janela de validade)
+		if expired := s.Blockchain.ExpireMempool(time.Now()); expired > 0 {
+			color.Yellow("⌛ [Miner] %d transações expiradas removidas da mempool", expired)
+		}
 		s.Blockchain.MX.Lock()
 		mempoolSize := len(s.Blockchain.MPool)
 		s.Blockchain.MX.Unlock()
 
+		var newBlock *blockchain.Block
+		var err error
 		if mempoolSize == 0 {
-			// nenhuma transação pendente
-			time.Sleep(2 * time.Second)
-			continue
-		}
-
-		color.Yellow("⛏️  [Miner] Minerando bloco com %d transações...", mempoolSize)
+			// nenhuma transação pendente: só minera se tiver bloco esperando finalidade
+			if !s.needsConfirmationBlock() {
+				sleepCtx(ctx, 2*time.Second)
+				continue
+			}
+			color.Yellow("⛏️  [Miner] Mempool vazia, minerando bloco de confirmação...")
+			newBlock, err = s.Blockchain.MineConfirmationBlock()
+		} else {
+			color.Yellow("⛏️  [Miner] Minerando bloco com %d transações...", mempoolSize)
 
-		// 2. tenta resolver o desafio 
-		newBlock, err := s.Blockchain.MineBlock()
+			// 2. tenta resolver o desafio
+			newBlock, err = s.Blockchain.MineBlock()
+		}
 
 		if err != nil {
 			if err.Error() == "mining cancelled" {
@@ -35,7 +53,7 @@ func (s *Server) RunMiner() {
 			} else {
 				color.Red("❌ [Miner] Erro ao minerar: %v", err)
 			}
-			time.Sleep(1 * time.Second)
+			sleepCtx(ctx, 1*time.Second)
 			continue
 		}
 
@@ -43,35 +61,39 @@ func (s *Server) RunMiner() {
 		s.Blockchain.AddBlock(newBlock)
 		color.Green("✅ [Miner] Bloco #%d minerado com sucesso!", s.Blockchain.Height-1)
 
-		// 4. broadcast do bloco minerado
-		go s.broadcastBlock(newBlock)
+		// 4. anuncia o bloco minerado (gossip)
+		go s.announceBlock(newBlock, "")
 
 		// 5. espera antes de poder minerar de novo
-		time.Sleep(500 * time.Millisecond)
+		sleepCtx(ctx, 500*time.Millisecond)
 	}
+	color.Yellow("⛏️  [Miner] Minerador parado")
 }
 
-// manda o bloco minerado pros outros servers
-func (s *Server) broadcastBlock(block interface{}) {
-	s.muLiveServers.RLock()
-	targets := make([]string, 0)
-	for id, alive := range s.liveServers {
-		if alive && id != s.ID {
-			if host, ok := s.serverList[id]; ok {
-				targets = append(targets, host)
+// tem tx de jogador em bloco provisório (abaixo do FINALITY_DEPTH) e a rede está parada há um tempo?
+// bloco só com coinbase não espera nada: sem essa checagem o final fica sempre depth-1 atrás
+// da ponta e os servidores minerariam bloco vazio pra sempre
+// o atraso tem um sorteio pra os servidores não minerarem o mesmo bloco vazio juntos
+func (s *Server) needsConfirmationBlock() bool {
+	final := int(s.finalHeight.Load())
+
+	s.Blockchain.MX.Lock()
+	ledger := s.Blockchain.Ledger
+	tipTime := time.Unix(ledger[len(ledger)-1].Timestamp, 0)
+	waiting := false
+	for h := len(ledger) - 1; h > final && h > 0 && !waiting; h-- {
+		for _, tx := range ledger[h].Transactions {
+			if tx.Type != models.TxCoinbase {
+				waiting = true
+				break
 			}
 		}
 	}
-	s.muLiveServers.RUnlock()
+	s.Blockchain.MX.Unlock()
 
-	for _, host := range targets {
-		go func(h string) {
-			err := s.sendToHost(h, "/blockchain/block", block)
-			if err != nil {
-				color.Red("❌ [Miner] Falha ao enviar bloco para %s: %v", h, err)
-			} else {
-				color.Green("📤 [Miner] Bloco enviado para %s", h)
-			}
-		}(host)
+	if !waiting {
+		return false
 	}
+	delay := ConfirmationBlockDelay + time.Duration(rand.Int63n(int64(ConfirmationBlockDelay)))
+	return time.Since(tipTime) >= delay
 }
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+)
+
+// autenticação entre servidores
+// cada nó tem um par de chaves ECDSA (P256); as chaves públicas ficam no NODE_KEYS_DIR
+// (um arquivo <id>.pub por nó, num volume que só os servidores montam, clientes não)
+// toda chamada servidor -> servidor vai assinada em cima de método, caminho,
+// timestamp, nonce e hash do corpo; as rotas internas recusam quem não assina
+//
+// sem NODE_KEYS_DIR (rodando fora do compose) o diretório é o hash do redis, mas aí a
+// primeira chave registrada pra um id vale pra sempre (HSETNX): ninguém sobrescreve
+// a chave de um nó que já existe, nem o próprio nó com uma chave nova
+
+var (
+	errNodeAuthMissing = errors.New("requisição sem assinatura de nó")
+	errNodeAuthExpired = errors.New("assinatura de nó fora da janela de tempo")
+	errNodeAuthReplay  = errors.New("requisição de nó já usada")
+	errNodeAuthInvalid = errors.New("assinatura de nó inválida")
+	errNodeAuthUnknown = errors.New("nó desconhecido")
+	errNodeKeyConflict = errors.New("id de nó já registrado com outra chave")
+)
+
+// carrega a chave do NODE_KEY_FILE (cria se não existir); sem arquivo gera uma efêmera
+func loadNodeKey(path string) (*ecdsa.PrivateKey, error) {
+	if path == "" {
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("arquivo de chave inválido: %s", path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, pemData, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// chave pública em hex (mesmo formato das chaves dos jogadores)
+func encodeNodePublicKey(pub *ecdsa.PublicKey) string {
+	return hex.EncodeToString(elliptic.Marshal(elliptic.P256(), pub.X, pub.Y))
+}
+
+func decodeNodePublicKey(val string) (*ecdsa.PublicKey, error) {
+	raw, err := hex.DecodeString(val)
+	if err != nil {
+		return nil, err
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), raw)
+	if x == nil {
+		return nil, fmt.Errorf("chave pública malformada")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
+// arquivo da chave pública de um nó no NODE_KEYS_DIR (id vira nome de arquivo, então nada de caminho)
+func nodeKeyFile(dir, nodeID string) (string, error) {
+	if nodeID == "" || nodeID == "." || nodeID == ".." || filepath.Base(nodeID) != nodeID {
+		return "", errNodeAuthUnknown
+	}
+	return filepath.Join(dir, nodeID+".pub"), nil
+}
+
+// registra minha chave no diretório sem nunca trocar a de um id que já existe
+func (s *Server) registerNodeKey(pub string) error {
+	if s.nodeKeysDir == "" {
+		if _, err := s.redisClient.HSetNX(s.ctx, NodeKeysKey, s.ID, pub).Result(); err != nil {
+			return err
+		}
+		current, err := s.redisClient.HGet(s.ctx, NodeKeysKey, s.ID).Result()
+		if err != nil {
+			return err
+		}
+		if current != pub {
+			return errNodeKeyConflict
+		}
+		return nil
+	}
+
+	path, err := nodeKeyFile(s.nodeKeysDir, s.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.nodeKeysDir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		current, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(string(current)) != pub {
+			return errNodeKeyConflict
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(pub + "\n")
+	return err
+}
+
+// publica minha chave no diretório (repete até conseguir, via health check)
+// conflito não adianta repetir: a chave desse id é outra, os peers vão recusar esse nó
+func (s *Server) publishNodeKey() {
+	if s.nodeKeyPublished.Load() {
+		return
+	}
+	pub := encodeNodePublicKey(&s.nodeKey.PublicKey)
+	err := s.registerNodeKey(pub)
+	if errors.Is(err, errNodeKeyConflict) {
+		s.nodeKeyPublished.Store(true)
+		color.Red("🔑 [Auth] %s já tem outra chave registrada, os peers vão recusar esse nó (use o NODE_KEY_FILE original)", s.ID)
+		return
+	}
+	if err != nil {
+		color.Red("🔑 [Auth] Falha ao publicar chave do nó: %v", err)
+		return
+	}
+	s.nodeKeyPublished.Store(true)
+	color.Green("🔑 [Auth] Chave do nó publicada (%s...)", pub[:16])
+}
+
+// chave pública registrada pra um nó (arquivo no NODE_KEYS_DIR ou hash do redis)
+func (s *Server) lookupNodeKey(nodeID string) (string, error) {
+	if s.nodeKeysDir == "" {
+		val, err := s.redisClient.HGet(s.ctx, NodeKeysKey, nodeID).Result()
+		if err != nil {
+			return "", errNodeAuthUnknown
+		}
+		return val, nil
+	}
+
+	path, err := nodeKeyFile(s.nodeKeysDir, nodeID)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", errNodeAuthUnknown
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// chave pública de outro nó: cache local, senão busca no diretório
+func (s *Server) nodePublicKey(nodeID string, refresh bool) (*ecdsa.PublicKey, error) {
+	if !refresh {
+		s.muNodeAuth.RLock()
+		key, ok := s.nodeKeys[nodeID]
+		s.muNodeAuth.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+
+	val, err := s.lookupNodeKey(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := decodeNodePublicKey(val)
+	if err != nil {
+		return nil, err
+	}
+
+	s.muNodeAuth.Lock()
+	s.nodeKeys[nodeID] = key
+	s.muNodeAuth.Unlock()
+	return key, nil
+}
+
+// chave de um servidor no formato que a blockchain guarda nas txs (coinbase)
+// a minha sai direto da memória, não depende de já ter conseguido publicar
+func (s *Server) nodeKeyBytes(nodeID string) ([]byte, bool) {
+	pub := &s.nodeKey.PublicKey
+	if nodeID != s.ID {
+		key, err := s.nodePublicKey(nodeID, false)
+		if err != nil {
+			return nil, false
+		}
+		pub = key
+	}
+	return elliptic.Marshal(elliptic.P256(), pub.X, pub.Y), true
+}
+
+// o que vai assinado: método, caminho (com query), quem assina, quando, nonce e hash do corpo
+// o nonce deixa duas chamadas iguais no mesmo segundo com digests diferentes
+func nodeSigningPayload(method, uri, nodeID, ts, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	msg := fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n%x", method, uri, nodeID, ts, nonce, bodyHash)
+	digest := sha256.Sum256([]byte(msg))
+	return digest[:]
+}
+
+// assina uma requisição de saída
+func (s *Server) signRequest(req *http.Request, body []byte) error {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(raw)
+	digest := nodeSigningPayload(req.Method, req.URL.RequestURI(), s.ID, ts, nonce, body)
+
+	sig, err := ecdsa.SignASN1(rand.Reader, s.nodeKey, digest)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(NodeIDHeader, s.ID)
+	req.Header.Set(NodeTimestampHeader, ts)
+	req.Header.Set(NodeNonceHeader, nonce)
+	req.Header.Set(NodeSignatureHeader, base64.StdEncoding.EncodeToString(sig))
+	return nil
+}
+
+// monta uma requisição já assinada pra outro servidor
+func (s *Server) newNodeRequest(method, url string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err := s.signRequest(req, body); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// confere a assinatura de quem chamou; devolve o id do nó
+// o corpo é lido e recolocado na requisição pros handlers conseguirem fazer o bind
+func (s *Server) verifyNodeRequest(c *gin.Context) (string, error) {
+	nodeID := c.GetHeader(NodeIDHeader)
+	tsHeader := c.GetHeader(NodeTimestampHeader)
+	nonce := c.GetHeader(NodeNonceHeader)
+	sigHeader := c.GetHeader(NodeSignatureHeader)
+	if nodeID == "" || tsHeader == "" || nonce == "" || sigHeader == "" {
+		return "", errNodeAuthMissing
+	}
+
+	ts, err := strconv.ParseInt(tsHeader, 10, 64)
+	if err != nil {
+		return "", errNodeAuthInvalid
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > NodeAuthMaxSkew || skew < -NodeAuthMaxSkew {
+		return "", errNodeAuthExpired
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(sigHeader)
+	if err != nil {
+		return "", errNodeAuthInvalid
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", errNodeAuthInvalid
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	digest := nodeSigningPayload(c.Request.Method, c.Request.URL.RequestURI(), nodeID, tsHeader, nonce, body)
+
+	key, err := s.nodePublicKey(nodeID, false)
+	if err != nil {
+		return "", err
+	}
+	if !ecdsa.VerifyASN1(key, digest, sig) {
+		// o cache pode ser de antes do nó se registrar, relê do diretório uma vez
+		key, err = s.nodePublicKey(nodeID, true)
+		if err != nil || !ecdsa.VerifyASN1(key, digest, sig) {
+			return "", errNodeAuthInvalid
+		}
+	}
+
+	// o anti replay vai no digest assinado, não na assinatura: ECDSA é maleável
+	// (r, s) e (r, n-s) valem pro mesmo digest, então a assinatura reenviada mudaria de cara
+	if !s.rememberNodeDigest(digest, ts) {
+		return "", errNodeAuthReplay
+	}
+	return nodeID, nil
+}
+
+// guarda os digests vistos dentro da janela, pra mesma requisição não ser reenviada
+func (s *Server) rememberNodeDigest(digest []byte, ts int64) bool {
+	now := time.Now().Unix()
+	window := int64(NodeAuthMaxSkew / time.Second)
+	key := hex.EncodeToString(digest)
+
+	s.muNodeAuth.Lock()
+	defer s.muNodeAuth.Unlock()
+
+	if _, seen := s.nodeDigests[key]; seen {
+		return false
+	}
+	for k, t := range s.nodeDigests {
+		if now-t > 2*window {
+			delete(s.nodeDigests, k)
+		}
+	}
+	s.nodeDigests[key] = ts
+	return true
+}
+
+// middleware das rotas internas (só outros servidores do cluster chamam)
+func (s *Server) nodeAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nodeID, err := s.verifyNodeRequest(c)
+		if err != nil {
+			color.Red("🔑 [Auth] %s %s recusado (%s): %v", c.Request.Method, c.Request.URL.Path, c.ClientIP(), err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(NodeIDContextKey, nodeID)
+		c.Next()
+	}
+}
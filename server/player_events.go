@@ -0,0 +1,235 @@
+package main
+
+import (
+	"PlanoZ/internal/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+)
+
+// replicação incremental do registro de players
+// o líder numera cada mudança (entrou/mudou/saiu) com um seq monotônico dentro do
+// seu termo e manda só o delta pros seguidores, que aplicam em ordem no cache local.
+// se faltar algum seq o seguidor pede os eventos que perdeu pro líder
+
+// quantos eventos o líder guarda pra atender resync
+const PlayerEventLogSize = 256
+
+// log de eventos do lado do líder
+type playerEventLog struct {
+	term   int64
+	seq    uint64
+	events []models.PlayerEvent
+}
+
+// estado de aplicação do lado do seguidor
+type playerEventState struct {
+	term      int64
+	seq       uint64
+	pending   map[uint64]models.PlayerEvent // chegaram fora de ordem
+	resyncing bool
+}
+
+// lado do líder
+
+// registra e propaga uma mudança no registro de players
+func (s *Server) emitPlayerEvent(kind, playerID string, info models.PlayerInfo) {
+	term := s.leaderTerm()
+
+	s.muPlayerEvents.Lock()
+	if s.playerEventLog.term != term {
+		// termo novo, numeração começa do zero
+		s.playerEventLog = playerEventLog{term: term}
+	}
+	s.playerEventLog.seq++
+	ev := models.PlayerEvent{
+		Seq:      s.playerEventLog.seq,
+		Term:     term,
+		LeaderID: s.ID,
+		Kind:     kind,
+		PlayerID: playerID,
+		Info:     info,
+	}
+	s.playerEventLog.events = append(s.playerEventLog.events, ev)
+	if len(s.playerEventLog.events) > PlayerEventLogSize {
+		s.playerEventLog.events = s.playerEventLog.events[len(s.playerEventLog.events)-PlayerEventLogSize:]
+	}
+	s.muPlayerEvents.Unlock()
+
+	go s.broadcastToServers("/players/update", ev)
+}
+
+// GET /players/events?since=N&term=T
+func (s *Server) handlePlayerEvents(c *gin.Context) {
+	reqTerm, _ := strconv.ParseInt(c.Query("term"), 10, 64)
+	if !s.checkLeaderTerm(c, reqTerm) {
+		return
+	}
+	since, _ := strconv.ParseUint(c.Query("since"), 10, 64)
+	term := s.leaderTerm()
+
+	s.muPlayerEvents.Lock()
+	defer s.muPlayerEvents.Unlock()
+
+	log := s.playerEventLog
+	if log.term != term {
+		// ainda não gerei nada nesse termo
+		log = playerEventLog{term: term}
+	}
+
+	resp := models.PlayerEventsResponse{Term: term, Seq: log.seq, Events: []models.PlayerEvent{}}
+
+	// o que ele pediu já saiu do log (ou é de outro termo): manda resetar o cache
+	if len(log.events) > 0 && since+1 < log.events[0].Seq {
+		resp.Reset = true
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	for _, ev := range log.events {
+		if ev.Seq > since {
+			resp.Events = append(resp.Events, ev)
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// lado do seguidor
+
+// POST /players/update
+func (s *Server) handlePlayerUpdate(c *gin.Context) {
+	var ev models.PlayerEvent
+	if err := c.ShouldBindJSON(&ev); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Evento inválido"})
+		return
+	}
+
+	// só aceita do líder do termo atual; se o termo for maior, relê o lease antes
+	if ev.Term > s.leaderTerm() {
+		s.refreshLeader()
+	}
+	s.muLeader.RLock()
+	leader, term := s.currentLeader, s.currentTerm
+	s.muLeader.RUnlock()
+
+	if ev.Term != term || ev.LeaderID != leader {
+		color.Red("👥 [Players] Evento rejeitado de %s (termo %d, atual %s/%d)", ev.LeaderID, ev.Term, leader, term)
+		c.JSON(http.StatusConflict, gin.H{"error": "Líder ou termo desatualizado", "leader": leader, "term": term})
+		return
+	}
+
+	if s.applyPlayerEvent(ev) {
+		go s.resyncPlayers()
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "applied"})
+}
+
+// aplica o evento em ordem, retorna true se ficou buraco (precisa resync)
+func (s *Server) applyPlayerEvent(ev models.PlayerEvent) bool {
+	s.muPlayerEvents.Lock()
+	defer s.muPlayerEvents.Unlock()
+
+	st := &s.playerEventState
+	if ev.Term != st.term {
+		// líder novo: o que está no cache pode ter perdido mudanças da transição
+		s.resetPlayerCache(ev.Term, 0)
+	}
+	if ev.Seq <= st.seq {
+		return false // duplicado
+	}
+
+	st.pending[ev.Seq] = ev
+	for {
+		next, ok := st.pending[st.seq+1]
+		if !ok {
+			break
+		}
+		s.applyToCache(next)
+		delete(st.pending, next.Seq)
+		st.seq = next.Seq
+	}
+	return len(st.pending) > 0
+}
+
+// pede pro líder os eventos que faltaram
+func (s *Server) resyncPlayers() {
+	s.muPlayerEvents.Lock()
+	if s.playerEventState.resyncing {
+		s.muPlayerEvents.Unlock()
+		return
+	}
+	s.playerEventState.resyncing = true
+	since, term := s.playerEventState.seq, s.playerEventState.term
+	s.muPlayerEvents.Unlock()
+
+	defer func() {
+		s.muPlayerEvents.Lock()
+		s.playerEventState.resyncing = false
+		s.muPlayerEvents.Unlock()
+	}()
+
+	s.muLeader.RLock()
+	leader := s.currentLeader
+	s.muLeader.RUnlock()
+	host, ok := s.hostOf(leader)
+	if !ok {
+		return
+	}
+
+	req, err := s.newNodeRequest(http.MethodGet, fmt.Sprintf("http://%s/players/events?since=%d&term=%d", host, since, term), nil)
+	if err != nil {
+		return
+	}
+	client := http.Client{Timeout: RequestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var data models.PlayerEventsResponse
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&data) != nil {
+		return
+	}
+
+	color.Cyan("👥 [Players] Resync com o líder %s: seq %d -> %d (reset=%v)", leader, since, data.Seq, data.Reset)
+
+	if data.Reset || data.Term != term {
+		s.muPlayerEvents.Lock()
+		s.resetPlayerCache(data.Term, data.Seq)
+		s.muPlayerEvents.Unlock()
+	}
+	for _, ev := range data.Events {
+		s.applyPlayerEvent(ev)
+	}
+}
+
+// chamar com muPlayerEvents travado
+// limpa o cache (o redis continua sendo a fonte) e recomeça a contagem
+func (s *Server) resetPlayerCache(term int64, seq uint64) {
+	s.playerEventState.term = term
+	s.playerEventState.seq = seq
+	for pseq := range s.playerEventState.pending {
+		if pseq <= seq {
+			delete(s.playerEventState.pending, pseq)
+		}
+	}
+
+	s.muPlayers.Lock()
+	s.playerList = make(map[string]cachedPlayer)
+	s.muPlayers.Unlock()
+}
+
+func (s *Server) applyToCache(ev models.PlayerEvent) {
+	switch ev.Kind {
+	case models.PlayerJoined, models.PlayerMoved:
+		s.cachePlayer(ev.PlayerID, ev.Info)
+	case models.PlayerLeft:
+		s.muPlayers.Lock()
+		delete(s.playerList, ev.PlayerID)
+		s.muPlayers.Unlock()
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"PlanoZ/internal/blockchain"
+	"PlanoZ/internal/models"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// registro de jogadores no redis cluster
+// cada jogador é um hash "player:<id>" com servidor, canal de resposta e last seen
+// a sessão expira sozinha (TTL) se o cliente parar de mandar heartbeat
+// os servidores guardam só um cache curto em memória (playerList)
+
+const (
+	PlayerKeyPrefix  = "player:"
+	PlayerSessionTTL = 60 * time.Second // sem heartbeat por esse tempo = offline
+	PlayerCacheTTL   = 5 * time.Second  // quanto tempo o cache local vale
+
+	SessionRequestPrefix = "planoz:session_req:" // request_ids de heartbeat/disconnect já usados
+)
+
+// entrada do cache local
+type cachedPlayer struct {
+	info      models.PlayerInfo
+	fetchedAt time.Time
+}
+
+func playerKey(playerID string) string {
+	return PlayerKeyPrefix + playerID
+}
+
+// grava (ou sobrescreve) a sessão do jogador, retorna a sessão anterior se tinha
+func (s *Server) registerPlayer(playerID string, info models.PlayerInfo) (models.PlayerInfo, bool, error) {
+	old, existed := s.fetchPlayer(playerID)
+
+	info.LastSeen = time.Now().Unix()
+	key := playerKey(playerID)
+	_, err := s.redisClient.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(s.ctx, key,
+			"server_id", info.ServerID,
+			"server_host", info.ServerHost,
+			"reply_channel", info.ReplyChannel,
+			"last_seen", info.LastSeen,
+		)
+		pipe.Expire(s.ctx, key, PlayerSessionTTL)
+		return nil
+	})
+	if err != nil {
+		return old, existed, err
+	}
+
+	s.cachePlayer(playerID, info)
+	return old, existed, nil
+}
+
+// renova o TTL e o last_seen numa tacada só, e só se a sessão ainda existir
+// (Expire e HSet separados deixavam a sessão expirar no meio e o HSet recriava
+// um hash só com last_seen e sem TTL)
+var touchPlayerScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'last_seen', ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1`)
+
+// heartbeat: renova o TTL, retorna false se a sessão já tinha expirado
+func (s *Server) touchPlayer(playerID string) (bool, error) {
+	n, err := touchPlayerScript.Run(s.ctx, s.redisClient, []string{playerKey(playerID)},
+		time.Now().Unix(), PlayerSessionTTL.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// remove a sessão do jogador
+func (s *Server) unregisterPlayer(playerID string) error {
+	s.muPlayers.Lock()
+	delete(s.playerList, playerID)
+	s.muPlayers.Unlock()
+
+	return s.redisClient.Del(s.ctx, playerKey(playerID)).Err()
+}
+
+// busca o jogador, primeiro no cache local e depois no redis
+func (s *Server) lookupPlayer(playerID string) (models.PlayerInfo, bool) {
+	s.muPlayers.RLock()
+	cached, ok := s.playerList[playerID]
+	s.muPlayers.RUnlock()
+
+	if ok && time.Since(cached.fetchedAt) < PlayerCacheTTL {
+		return cached.info, true
+	}
+
+	info, found := s.fetchPlayer(playerID)
+	if !found {
+		s.muPlayers.Lock()
+		delete(s.playerList, playerID)
+		s.muPlayers.Unlock()
+		return models.PlayerInfo{}, false
+	}
+
+	s.cachePlayer(playerID, info)
+	return info, true
+}
+
+// lê direto do redis
+func (s *Server) fetchPlayer(playerID string) (models.PlayerInfo, bool) {
+	fields, err := s.redisClient.HGetAll(s.ctx, playerKey(playerID)).Result()
+	if err != nil || len(fields) == 0 {
+		return models.PlayerInfo{}, false
+	}
+
+	lastSeen, _ := strconv.ParseInt(fields["last_seen"], 10, 64)
+	return models.PlayerInfo{
+		ServerID:     fields["server_id"],
+		ServerHost:   fields["server_host"],
+		ReplyChannel: fields["reply_channel"],
+		LastSeen:     lastSeen,
+	}, true
+}
+
+func (s *Server) cachePlayer(playerID string, info models.PlayerInfo) {
+	s.muPlayers.Lock()
+	s.playerList[playerID] = cachedPlayer{info: info, fetchedAt: time.Now()}
+	s.muPlayers.Unlock()
+}
+
+// handlers de sessão
+// heartbeat e disconnect vêm assinados pelo jogador (TransactionRequest do tipo HB/DC),
+// senão qualquer um derrubava ou mantinha viva a sessão de outro só sabendo o id
+
+// confere o request de sessão e retorna o id do jogador; false = resposta de erro já enviada
+func (s *Server) bindSessionRequest(c *gin.Context, tipo models.TransactionType) (string, bool) {
+	var req models.TransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.UserID == "" || req.Type != tipo {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return "", false
+	}
+	if status, err := s.verifyClientRequest(req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if status, err := checkUserKey(s.Blockchain.PendingState(), req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return "", false
+	}
+
+	// request de sessão não vira tx, então o anti-replay do ledger não pega: marca o
+	// request_id no redis (vale pro cluster todo) pelo tempo da janela do request
+	key := SessionRequestPrefix + hex.EncodeToString(req.PublicKey) + ":" + req.RequestID
+	first, err := s.redisClient.SetNX(s.ctx, key, s.ID, blockchain.RequestMaxAge+blockchain.MaxFutureDrift).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if !first {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request já utilizado"})
+		return "", false
+	}
+	return req.UserID, true
+}
+
+// POST /players/heartbeat
+func (s *Server) handlePlayerHeartbeat(c *gin.Context) {
+	playerID, ok := s.bindSessionRequest(c, models.ReqHeartbeat)
+	if !ok {
+		return
+	}
+
+	alive, err := s.touchPlayer(playerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !alive {
+		// sessão expirou, o cliente tem que se registrar de novo
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sessão expirada"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "alive", "ttl": int(PlayerSessionTTL.Seconds())})
+}
+
+// POST /players/disconnect (só líder, pra poder numerar o evento de saída)
+func (s *Server) handlePlayerDisconnect(c *gin.Context) {
+	playerID, ok := s.bindSessionRequest(c, models.ReqDisconnect)
+	if !ok {
+		return
+	}
+
+	if err := s.unregisterPlayer(playerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	color.Yellow("Player %s desconectou", playerID)
+	s.emitPlayerEvent(models.PlayerLeft, playerID, models.PlayerInfo{})
+	c.JSON(http.StatusOK, gin.H{"status": "disconnected"})
+}
@@ -4,35 +4,58 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// configura o router do gin e as rotas
-func (s *Server) setupRouter() *gin.Engine {
-	// gin.SetMode(gin.ReleaseMode) 
+// duas apis em portas separadas:
+// publica (API_PORT) pros clientes e interna (CLUSTER_PORT) só pra outros servidores
+
+// api publica: rotas do cliente, com CORS e rate limit
+func (s *Server) setupPublicRouter() *gin.Engine {
+	// gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
+	r.Use(corsMiddleware(s.api.CORSOrigins))
+	r.Use(rateLimitMiddleware(s.api.RateLimit, s.api.RateBurst))
 
 	// rota de heartbeating e eleição
 	r.GET("/health", s.handleHealthCheck)
 
+	// prontidão (só 200 depois que a eleição começou e as condições batem)
+	r.GET("/ready", s.handleReady)
+
+	// eventos em tempo real (SSE): ?topics=blocks,txs,leader
+	r.GET("/events", s.handleEvents)
+
 	// --- rotas da blockchain ---
 	blockchainGroup := r.Group("/blockchain")
 	{
 		// visualizacao
 		blockchainGroup.GET("/", s.handleGetBlockchain)     // ver o ledger todo
 		blockchainGroup.GET("/mempool", s.handleGetMempool) // ver transações pendentes
-
-		// p2p da blockchain
-		blockchainGroup.POST("/block", s.handleReceiveBlock) // recebe bloco de outro server
+		blockchainGroup.GET("/miners", s.handleMinerStats)  // blocos e recompensas por servidor
 	}
 
-	// --- rotas de sync (lider x seguidores) ---
+	// acompanhamento de tx (o tx_id vem no AsyncResponse)
+	r.GET("/tx/:id/status", s.handleTxStatus)
+
+	// explorer (consultas paginadas em cima dos índices da cadeia)
+	explorerGroup := r.Group("/explorer")
+	{
+		explorerGroup.GET("/blocks", s.handleExplorerBlocks)
+		explorerGroup.GET("/blocks/:id", s.handleExplorerBlock)
+		explorerGroup.GET("/tx/:id", s.handleExplorerTx)
+		explorerGroup.GET("/players/:id/txs", s.handleExplorerPlayerTxs)
+		explorerGroup.GET("/types/:type/txs", s.handleExplorerTypeTxs)
+		explorerGroup.GET("/cards/:id/txs", s.handleExplorerCardTxs)
+		explorerGroup.GET("/stats", s.handleExplorerStats)
+	}
 
 	// player management
 	playerGroup := r.Group("/players")
 	{
-		// seguidor avisa lider sobre novos jogadores
-		playerGroup.POST("/connect", s.handleLeaderConnect)
+		// cliente se registra (se cair num seguidor, ele encaminha pro líder pela api interna)
+		playerGroup.POST("/connect", s.leaderOnly(), s.handleLeaderConnect)
 
-		// lider manda a lista atualizada para os servers seguidores
-		playerGroup.POST("/update", s.handlePlayerUpdate)
+		// sessão do jogador no registro do redis
+		playerGroup.POST("/heartbeat", s.handlePlayerHeartbeat)
+		playerGroup.POST("/disconnect", s.leaderOnly(), s.handlePlayerDisconnect)
 	}
 
 	// cartas e compras
@@ -40,35 +63,92 @@ func (s *Server) setupRouter() *gin.Engine {
 	{
 		// seguidor pede para lider processar compra (sincronização de dados do sistema distribuído)
 		cardGroup.POST("/buy", s.handleLeaderBuyCard)
+
+		// queima duplicatas pra fabricar carta mais rara
+		cardGroup.POST("/craft", s.handleCraftCard)
+
+		// proveniência: mint, trocas, batalhas, craft e mercado
+		cardGroup.GET("/:id/history", s.handleCardHistory)
 	}
 
-	// inventário
-	inventoryGroup := r.Group("/inventory")
+	// mercado (anúncios pagos em tokens)
+	marketGroup := r.Group("/market")
 	{
-		// líder avisa mudança de estoque
-		inventoryGroup.POST("/update", s.handleInventoryUpdate)
+		// navegação
+		marketGroup.GET("", s.handleListMarket)
+		marketGroup.GET("/listings/:id", s.handleGetListing)
+		marketGroup.GET("/balance/:player", s.handleGetBalance)
+
+		// transações assinadas
+		marketGroup.POST("/list", s.handleMarketList)
+		marketGroup.POST("/cancel", s.handleMarketCancel)
+		marketGroup.POST("/buy", s.handleMarketBuy)
 	}
 
 	// chamadas de fim de jogo/troca pra registrar na chain
 	r.POST("/battle/register", s.handleRegisterBattle)
 	r.POST("/trade/register", s.handleRegisterTrade)
 
-	// --- rotas de gameplay p2p (tempo real) ---
+	// batalha: pedido assinado de quem desafia e de quem aceita
+	r.POST("/battle/start", s.handleBattleStart)
+
+	// cliente pede pra começar troca
+	r.POST("/trade/initiate", s.handleTradeInitiate)
+
+	return r
+}
+
+// api interna: tudo que é servidor -> servidor, exige requisição assinada pela chave do nó
+func (s *Server) setupClusterRouter() *gin.Engine {
+	r := gin.Default()
+
+	// health fica aberto (é só o ping dos outros nós)
+	r.GET("/health", s.handleHealthCheck)
+
+	internal := r.Group("/", s.nodeAuth())
+
+	// membership dinamica do cluster
+	clusterGroup := internal.Group("/cluster")
+	{
+		clusterGroup.POST("/join", s.handleClusterJoin)
+		clusterGroup.POST("/leave", s.handleClusterLeave)
+		clusterGroup.GET("/members", s.handleClusterMembers)
+	}
+
+	// p2p da blockchain
+	blockchainGroup := internal.Group("/blockchain")
+	{
+		blockchainGroup.GET("/", s.handleGetBlockchain)      // sync de cadeia
+		blockchainGroup.POST("/block", s.handleReceiveBlock) // recebe bloco de outro server
+		blockchainGroup.POST("/tx", s.handleReceiveTx)       // recebe tx repassada
+		blockchainGroup.POST("/inv", s.handleInv)            // anúncio: responde o que falta
+	}
 
-	// rotas para simplificar batalhas 
-	battleGroup := r.Group("/battle")
+	// --- rotas de sync (lider x seguidores) ---
+	playerGroup := internal.Group("/players")
 	{
-		battleGroup.POST("/initiate", s.handleBattleInitiate)
-		battleGroup.POST("/request_move", s.handleBattleRequestMove)
-		battleGroup.POST("/turn_result", s.handleBattleTurnResult)
-		battleGroup.POST("/end", s.handleBattleEnd)
-		battleGroup.POST("/submit_move", s.handleBattleSubmitMove)
+		// seguidor encaminha pro líder (cliente ou fila de comandos)
+		playerGroup.POST("/connect", s.leaderOnly(), s.handleLeaderConnect)
+		playerGroup.POST("/disconnect", s.leaderOnly(), s.handlePlayerDisconnect)
+
+		// replicação incremental: líder manda deltas, seguidor pede o que perdeu
+		playerGroup.POST("/update", s.handlePlayerUpdate)
+		playerGroup.GET("/events", s.handlePlayerEvents)
 	}
 
+	// inventário
+	inventoryGroup := internal.Group("/inventory")
+	{
+		// líder avisa mudança de estoque
+		inventoryGroup.POST("/update", s.handleInventoryUpdate)
+	}
+
+	// --- rotas de gameplay p2p (tempo real) ---
+	// batalha não tem passo entre servidores: o host resolve tudo e avisa os dois jogadores
+
 	// rotas de troca
-	tradeGroup := r.Group("/trade")
+	tradeGroup := internal.Group("/trade")
 	{
-		tradeGroup.POST("/initiate", s.handleTradeInitiate)
 		tradeGroup.POST("/request_card", s.handleTradeRequestCard)
 		tradeGroup.POST("/result", s.handleTradeResult)
 		tradeGroup.POST("/submit_card", s.handleTradeSubmitCard)
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"PlanoZ/internal/models"
+	"bufio"
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+)
+
+// orquestração de startup: em modo auto o servidor espera as condições de
+// prontidão (redis, peers, cadeia sincronizada) e começa health checks e eleição
+// sozinho, sem precisar de alguém apertar ENTER no container
+
+const (
+	StartupAuto   = "auto"
+	StartupManual = "manual"
+
+	// de quanto em quanto tempo o loop de fundo reavalia a prontidão servida no /ready
+	ReadinessRefreshInterval = 2 * time.Second
+)
+
+type StartupConfig struct {
+	Mode         string        // STARTUP_MODE: auto | manual
+	MinPeers     int           // READY_MIN_PEERS: quantos outros servidores precisa ver
+	RequireRedis bool          // READY_REQUIRE_REDIS
+	RequireSync  bool          // READY_REQUIRE_SYNC: cadeia local >= maior cadeia dos peers
+	Timeout      time.Duration // READY_TIMEOUT: depois disso sobe mesmo sem tudo pronto
+}
+
+func loadStartupConfig() StartupConfig {
+	return StartupConfig{
+		Mode:         envString("STARTUP_MODE", StartupAuto),
+		MinPeers:     envInt("READY_MIN_PEERS", 0),
+		RequireRedis: envBool("READY_REQUIRE_REDIS", true),
+		RequireSync:  envBool("READY_REQUIRE_SYNC", true),
+		Timeout:      envDuration("READY_TIMEOUT", 30*time.Second),
+	}
+}
+
+// espera o gatilho de startup (ENTER ou condições de prontidão) e sobe health checks + eleição
+func (s *Server) runStartup() {
+	if s.startup.Mode == StartupManual {
+		color.Yellow("\nPressione ENTER para iniciar Health Checks e Eleição...")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	} else {
+		s.waitUntilReady()
+	}
+
+	// entra no cluster pelos seeds e começa o heartbeating
+	s.joinCluster()
+	s.life.Go(s.RunHealthChecks)
+
+	// primeira rodada de health + eleição (depois segue no RunHealthChecks)
+	color.Yellow("\nIniciando eleição de líder...")
+	s.checkClusterHealth()
+
+	s.muLiveServers.RLock()
+	color.Green("Nós vivos detectados: %d", len(s.liveServers))
+	for id, alive := range s.liveServers {
+		if alive {
+			color.Green("  ✓ %s", id)
+		}
+	}
+	s.muLiveServers.RUnlock()
+
+	s.muReady.Lock()
+	s.started = true
+	s.muReady.Unlock()
+
+	// daqui pra frente o /ready só lê o cache que esse loop mantém
+	s.life.Go(s.RunReadinessLoop)
+}
+
+// reavalia a prontidão em background (a avaliação entra no cluster e sincroniza a cadeia,
+// então não pode rodar dentro de um GET que qualquer um chama)
+func (s *Server) RunReadinessLoop(ctx context.Context) {
+	for {
+		s.evaluateReadiness()
+		if !sleepCtx(ctx, ReadinessRefreshInterval) {
+			return
+		}
+	}
+}
+
+// fica reavaliando as condições até ficar pronto ou estourar o timeout
+func (s *Server) waitUntilReady() {
+	color.Yellow("\n[Startup] Modo automático: aguardando condições de prontidão (timeout %s)...", s.startup.Timeout)
+	deadline := time.Now().Add(s.startup.Timeout)
+
+	for {
+		st := s.evaluateReadiness()
+		if s.conditionsMet(st) {
+			color.Green("[Startup] Pronto! redis=%v peers=%d/%d altura=%d/%d",
+				st.RedisOK, st.Peers, st.MinPeers, st.Height, st.PeerHeight)
+			return
+		}
+		if time.Now().After(deadline) {
+			color.Red("[Startup] Timeout de prontidão, subindo assim mesmo (redis=%v peers=%d/%d sync=%v)",
+				st.RedisOK, st.Peers, st.MinPeers, st.Synced)
+			return
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// checa redis, descobre peers e sincroniza a cadeia, guardando o resultado pro /ready
+func (s *Server) evaluateReadiness() models.ReadinessResponse {
+	st := models.ReadinessResponse{
+		Mode:     s.startup.Mode,
+		MinPeers: s.startup.MinPeers,
+	}
+
+	st.RedisOK = s.redisClient.Ping(s.ctx).Err() == nil
+
+	// sozinho: tenta os seeds de novo
+	if len(s.snapshotServerList()) <= 1 {
+		s.joinCluster()
+	}
+
+	// syncChain já faz o probe dos peers
+	st.PeerHeight = s.syncChain()
+	st.Height = s.Blockchain.CurrentHeight()
+	st.Synced = st.Height >= st.PeerHeight
+	st.Peers = len(s.snapshotServerList()) - 1
+
+	s.muReady.Lock()
+	st.Started = s.started
+	st.Ready = st.Started && s.conditionsMet(st)
+	st.CheckedAt = time.Now().Unix()
+	s.readiness = st
+	s.muReady.Unlock()
+
+	return st
+}
+
+func (s *Server) conditionsMet(st models.ReadinessResponse) bool {
+	if s.startup.RequireRedis && !st.RedisOK {
+		return false
+	}
+	if st.Peers < s.startup.MinPeers {
+		return false
+	}
+	if s.startup.RequireSync && !st.Synced {
+		return false
+	}
+	return true
+}
+
+// GET /ready
+// diferente do /health (que só diz que o processo está de pé), aqui só responde 200
+// quando o servidor já começou a eleição e as condições de prontidão batem (e não está desligando)
+func (s *Server) handleReady(c *gin.Context) {
+	// só o que o RunReadinessLoop já avaliou (checked_at diz de quando é)
+	s.muReady.RLock()
+	st := s.readiness
+	s.muReady.RUnlock()
+
+	status := http.StatusOK
+	if s.shuttingDown() {
+		st.Ready = false
+	}
+	if !st.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, st)
+}
@@ -1,33 +1,58 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
+	"PlanoZ/internal/models"
+
 	"github.com/fatih/color"
+	"github.com/redis/go-redis/v9"
 )
 
 // manda resposta pro canal do jogador no redis
 func (s *Server) sendToClient(canal string, tipo string, payload interface{}) {
-	msg := map[string]interface{}{
-		"tipo":    tipo,
-		"payload": payload,
+	data, err := models.EncodeNotification(tipo, payload)
+	if err != nil {
+		color.Red("Erro ao montar notificação %s: %v", tipo, err)
+		return
 	}
-	data, _ := json.Marshal(msg)
 
-	err := s.redisClient.Publish(context.Background(), canal, data).Err()
+	// stream em vez de publish: se o cliente estiver reconectando a mensagem fica
+	// guardada e ele relê a partir do ultimo XACK
+	ctx := context.Background()
+	err = s.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: canal,
+		MaxLen: ReplyStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"data": string(data)},
+	}).Err()
 	if err != nil {
-		color.Red("Erro ao publicar no Redis (%s): %v", canal, err)
+		color.Red("Erro ao enviar pro stream do Redis (%s): %v", canal, err)
+		return
 	}
+	s.redisClient.Expire(ctx, canal, ReplyStreamTTL)
 }
 
-// faz um post simples pra outro server
+// faz um post assinado pra outro server
 func (s *Server) sendToHost(host string, endpoint string, payload interface{}) error {
+	return s.postToHost(host, endpoint, payload, nil)
+}
+
+// o outro servidor respondeu, mas com status de erro
+type statusError struct{ code int }
+
+func (e statusError) Error() string { return fmt.Sprintf("status code %d", e.code) }
+
+// igual o sendToHost, mas decodifica a resposta em out (se não for nil)
+func (s *Server) postToHost(host string, endpoint string, payload interface{}, out interface{}) error {
 	url := fmt.Sprintf("http://%s%s", host, endpoint)
 
 	jsonData, err := json.Marshal(payload)
@@ -35,31 +60,30 @@ func (s *Server) sendToHost(host string, endpoint string, payload interface{}) e
 		return err
 	}
 
+	req, err := s.newNodeRequest(http.MethodPost, url, jsonData)
+	if err != nil {
+		return err
+	}
+
 	client := &http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
-		return fmt.Errorf("status code %d", resp.StatusCode)
+		return statusError{code: resp.StatusCode}
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
 	}
 	return nil
 }
 
 // broadcast de mensangens
 func (s *Server) broadcastToServers(endpoint string, payload interface{}) {
-	s.muLiveServers.RLock()
-	targets := make([]string, 0)
-	for id, alive := range s.liveServers {
-		if alive && id != s.ID {
-			if host, ok := s.serverList[id]; ok {
-				targets = append(targets, host)
-			}
-		}
-	}
-	s.muLiveServers.RUnlock()
+	targets := s.liveTargets()
 
 	for _, host := range targets {
 		go func(h string) {
@@ -68,11 +92,41 @@ func (s *Server) broadcastToServers(endpoint string, payload interface{}) {
 	}
 }
 
+// hosts dos servidores vivos (tirando eu), olhando a membership atual
+func (s *Server) liveTargets() []string {
+	targets := make([]string, 0)
+	for _, host := range s.livePeers() {
+		targets = append(targets, host)
+	}
+	return targets
+}
+
+// id -> host dos servidores vivos (tirando eu)
+func (s *Server) livePeers() map[string]string {
+	servers := s.snapshotServerList()
+
+	s.muLiveServers.RLock()
+	defer s.muLiveServers.RUnlock()
+
+	peers := make(map[string]string)
+	for id, alive := range s.liveServers {
+		if alive && id != s.ID {
+			if host, ok := servers[id]; ok {
+				peers[id] = host
+			}
+		}
+	}
+	return peers
+}
+
 // função auxilicar para o Ping
 func (s *Server) lidarPing(conn *net.UDPConn) {
 	buffer := make([]byte, 1024)
 	for {
 		n, addr, err := conn.ReadFromUDP(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return // desligando
+		}
 		if err != nil {
 			continue
 		}
@@ -81,3 +135,45 @@ func (s *Server) lidarPing(conn *net.UDPConn) {
 		}
 	}
 }
+
+// dorme d ou até o ctx ser cancelado; false = cancelado
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// helpers pra ler config do ambiente com valor padrão
+
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func envBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}